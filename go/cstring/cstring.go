@@ -0,0 +1,333 @@
+// Package cstring quotes and unquotes C-like string literals
+// extended with the matchertext escapes that the matchertext paper proposes.
+//
+// A matchertext escape \[m] embeds the matchertext m in a literal verbatim.
+// The embedded matchertext is uninterpreted except to verify
+// that its matchers match and to find its terminating close bracket,
+// so that quotes, backslashes, and newlines are not special within m:
+// for example, the literal "\["'\]" is equivalent to "\"\'\\".
+// The paired escapes \o() and \c() represent an open or close parenthesis,
+// and similarly \o[], \c[], \o{}, and \c{} represent brackets and braces,
+// so that a literal may contain unmatched matchers
+// while itself remaining valid matchertext.
+//
+// Literals otherwise use the escapes that C and Go have in common:
+// \a \b \f \n \r \t \v \\ \' \" for special characters,
+// \x followed by exactly two hexadecimal digits,
+// \ followed by up to three octal digits,
+// and \u or \U followed by four or eight hexadecimal digits
+// to represent a Unicode character in UTF-8.
+package cstring
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/dedis/matchertext/go/matchertext"
+)
+
+// Kinds of syntax errors in C-like string literals.
+const (
+	ExpectedQuote       matchertext.ErrorKind = "expecting quote"
+	UnterminatedLiteral matchertext.ErrorKind = "unterminated string literal"
+	InvalidEscape       matchertext.ErrorKind = "invalid escape sequence"
+	ExpectedEnd         matchertext.ErrorKind = "expected end of literal"
+)
+
+// Extract finds the end of the C-like string literal
+// that starts with the single or double quote at offset start in src,
+// and returns the offset of its closing quote.
+// The literal is thus src[start:end+1].
+//
+// Extract skips the character following each backslash,
+// and uses matchertext.Parser.Extract to skip the embedded matchertext
+// of each matchertext escape \[m], which may contain quotes and newlines.
+// It does not otherwise check the validity of escape sequences,
+// so it works with any C-like language's escapes.
+//
+// Extract returns -1 and a matchertext.SyntaxError,
+// with offsets relative to the start of src,
+// if the literal ends before its closing quote
+// or contains an invalid matchertext escape.
+func Extract(src []byte, start int) (end int, err error) {
+	l, err := newLiteral(bytes.NewReader(src), start)
+	if err == nil {
+		err = l.scan(nil)
+	}
+	if err != nil {
+		return -1, err
+	}
+	return int(l.p.Offset()), nil
+}
+
+// Unquote interprets s as a single- or double-quoted C-like string literal,
+// and returns the string value that s represents.
+// The quote character is not significant,
+// so a single-quoted literal may hold any number of characters.
+//
+// Unquote returns a matchertext.SyntaxError
+// if s is not exactly one valid literal.
+func Unquote(s string) (string, error) {
+	l, err := newLiteral(strings.NewReader(s), 0)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := l.scan(&b); err != nil {
+		return "", err
+	}
+	if _, err := l.p.ReadByte(); err != io.EOF {
+		return "", l.p.SyntaxErrorKind(ExpectedEnd, string(ExpectedEnd))
+	}
+	return b.String(), nil
+}
+
+// Quote returns a double-quoted C-like string literal representing s,
+// using only the standard escapes that C and Go have in common.
+// Quote escapes control characters, invalid UTF-8 bytes,
+// and non-printable characters, but leaves printable characters intact.
+// The result may contain unmatched matchers.
+func Quote(s string) string {
+	return string(appendQuoted(nil, s, '"', nil))
+}
+
+// QuoteMatchertext returns a double-quoted C-like string literal
+// representing s that is itself valid matchertext.
+// If s is valid matchertext consisting only of printable characters
+// and requires escaping in a standard literal,
+// QuoteMatchertext embeds it verbatim as a matchertext escape \[s].
+// Otherwise QuoteMatchertext escapes s as Quote does,
+// but also escapes each unmatched matcher using a paired escape like \o().
+func QuoteMatchertext(s string) string {
+	mask := matchertext.Unmatched(nil, []byte(s))
+	plain, embeddable := true, s != ""
+	for i, r := range s {
+		if mask[i] != 0 || r == utf8.RuneError || !unicode.IsPrint(r) {
+			embeddable = false
+		}
+		if r == '"' || r == '\\' || !unicode.IsPrint(r) {
+			plain = false
+		}
+	}
+	if !plain && embeddable {
+		return `"\[` + s + `]"`
+	}
+	return string(appendQuoted(nil, s, '"', mask))
+}
+
+// Standard converts the C-like string literal lit,
+// which may use matchertext escapes, into an equivalent literal
+// with the same quote character that uses only standard escapes.
+func Standard(lit string) (string, error) {
+	s, err := Unquote(lit)
+	if err != nil {
+		return "", err
+	}
+	return string(appendQuoted(nil, s, lit[0], nil)), nil
+}
+
+// Append to b a literal representing s quoted with q,
+// also escaping with paired escapes the matchers that are nonzero in mask.
+func appendQuoted(b []byte, s string, q byte, mask []byte) []byte {
+	b = append(b, q)
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			b = fmt.Appendf(b, `\x%02x`, s[i])
+		case mask != nil && mask[i] != 0:
+			b = append(b, matchertext.CPairEscapes[s[i]]...)
+		case r == rune(q) || r == '\\':
+			b = append(b, '\\', byte(r))
+		case r < utf8.RuneSelf && simpleEscapes[r] != 0:
+			b = append(b, '\\', simpleEscapes[r])
+		case r < ' ' || r == 0x7F:
+			// Octal escapes end after three digits, unlike C's \x
+			b = fmt.Appendf(b, `\%03o`, r)
+		case !unicode.IsPrint(r) && r < 0x10000:
+			b = fmt.Appendf(b, `\u%04x`, r)
+		case !unicode.IsPrint(r):
+			b = fmt.Appendf(b, `\U%08x`, r)
+		default:
+			b = append(b, s[i:i+size]...)
+		}
+		i += size
+	}
+	return append(b, q)
+}
+
+// Single-letter escapes for control characters, indexed by character
+var simpleEscapes = [utf8.RuneSelf]byte{
+	'\a': 'a', '\b': 'b', '\f': 'f', '\n': 'n',
+	'\r': 'r', '\t': 't', '\v': 'v',
+}
+
+// Characters that single-letter escapes represent, indexed by letter
+var escapeChars = [utf8.RuneSelf]byte{
+	'a': '\a', 'b': '\b', 'f': '\f', 'n': '\n',
+	'r': '\r', 't': '\t', 'v': '\v',
+	'\\': '\\', '\'': '\'', '"': '"', '?': '?',
+}
+
+// literal scans a C-like string literal using a matchertext parser.
+type literal struct {
+	p *matchertext.Parser
+	q byte // the quote character
+}
+
+// Create a literal scanner for the literal starting at offset start of r,
+// and consume its opening quote.
+func newLiteral(r io.Reader, start int) (*literal, error) {
+	l := &literal{p: matchertext.NewParser(r)}
+	for range start {
+		if _, err := l.p.ReadByte(); err != nil {
+			return nil, l.p.SyntaxErrorKind(ExpectedQuote,
+				string(ExpectedQuote))
+		}
+	}
+	q, err := l.p.ReadByte()
+	if err != nil || q != '"' && q != '\'' {
+		return nil, l.p.SyntaxErrorKind(ExpectedQuote, string(ExpectedQuote))
+	}
+	l.q = q
+	return l, nil
+}
+
+// Scan the rest of the literal through its closing quote.
+// If b is non-nil, decode the literal's value into b,
+// reporting invalid escapes;
+// otherwise skip the character following each backslash.
+func (l *literal) scan(b *strings.Builder) error {
+	p := l.p
+	for {
+		c, err := p.ReadByte()
+		switch {
+		case err == io.EOF || err == nil && c == '\n':
+			return p.SyntaxErrorKind(UnterminatedLiteral,
+				string(UnterminatedLiteral))
+		case err != nil:
+			return err
+		case c == l.q:
+			return nil
+		case c != '\\':
+			if b != nil {
+				b.WriteByte(c)
+			}
+			continue
+		}
+
+		// Embed any matchertext escape verbatim
+		if c, err := p.PeekByte(); err == nil && c == '[' {
+			w := io.Discard
+			if b != nil {
+				w = b
+			}
+			if _, _, err := p.Extract(w); err != nil {
+				return err
+			}
+			continue
+		}
+
+		c, err = p.ReadByte()
+		if err == io.EOF {
+			return p.SyntaxErrorKind(UnterminatedLiteral,
+				string(UnterminatedLiteral))
+		}
+		if err != nil {
+			return err
+		}
+		if b != nil {
+			if err := l.escape(b, c); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Decode the escape sequence starting with c following a backslash.
+func (l *literal) escape(b *strings.Builder, c byte) error {
+	p := l.p
+	invalid := func() error {
+		return p.SyntaxErrorKind(InvalidEscape,
+			fmt.Sprintf("invalid escape sequence \\%c", c))
+	}
+
+	switch {
+	case c < utf8.RuneSelf && escapeChars[c] != 0:
+		b.WriteByte(escapeChars[c])
+
+	case c == 'o' || c == 'c':
+		// A paired escape like \o() for one matcher
+		o, err1 := p.ReadByte()
+		cl, err2 := p.ReadByte()
+		if err1 != nil || err2 != nil || !matchertext.IsMatched(o, cl) {
+			return invalid()
+		}
+		if c == 'o' {
+			b.WriteByte(o)
+		} else {
+			b.WriteByte(cl)
+		}
+
+	case '0' <= c && c <= '7':
+		v := int(c - '0')
+		for range 2 {
+			d, err := p.PeekByte()
+			if err != nil || d < '0' || d > '7' {
+				break
+			}
+			p.ReadByte()
+			v = v*8 + int(d-'0')
+		}
+		if v > 0xFF {
+			return invalid()
+		}
+		b.WriteByte(byte(v))
+
+	case c == 'x' || c == 'u' || c == 'U':
+		n := 2
+		switch c {
+		case 'u':
+			n = 4
+		case 'U':
+			n = 8
+		}
+		var v rune
+		for range n {
+			d, err := p.ReadByte()
+			if err != nil || unhex(d) < 0 {
+				return invalid()
+			}
+			v = v<<4 | rune(unhex(d))
+		}
+		switch {
+		case c == 'x':
+			b.WriteByte(byte(v))
+		case !utf8.ValidRune(v):
+			return invalid()
+		default:
+			b.WriteRune(v)
+		}
+
+	default:
+		return invalid()
+	}
+	return nil
+}
+
+// Return the value of hexadecimal digit d, or -1 if it is not one.
+func unhex(d byte) int {
+	switch {
+	case '0' <= d && d <= '9':
+		return int(d - '0')
+	case 'a' <= d && d <= 'f':
+		return int(d - 'a' + 10)
+	case 'A' <= d && d <= 'F':
+		return int(d - 'A' + 10)
+	}
+	return -1
+}
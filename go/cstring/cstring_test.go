@@ -0,0 +1,164 @@
+package cstring
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"strconv"
+	"testing"
+
+	"github.com/dedis/matchertext/go/matchertext"
+)
+
+func TestUnquote(t *testing.T) {
+	for i, tc := range []struct{ lit, val string }{
+		{`""`, ""},
+		{`"hello!\n"`, "hello!\n"},
+		{`"\["'\]"`, `"'\`},
+		{`"\"\'\\"`, `"'\`},
+		{`'it\'s'`, "it's"},
+		{`'\["]'`, `"`},
+		{"\"\\[a\nb(c)]\"", "a\nb(c)"},
+		{`"\[]"`, ""},
+		{`"\o()\c()\o[]\c[]\o{}\c{}"`, "()[]{}"},
+		{`"f\o()x"`, "f(x"},
+		{`"\a\b\f\r\t\v\?"`, "\a\b\f\r\t\v?"},
+		{`"\x41\x5b\x5D"`, "A[]"},
+		{`"\0\101\1234"`, "\x00A" + "S4"},
+		{`"\u00e9\U0001F600"`, "é😀"},
+	} {
+		val, err := Unquote(tc.lit)
+		if err != nil || val != tc.val {
+			t.Errorf("%v %v: got %q, %v, expected %q",
+				i, tc.lit, val, err, tc.val)
+		}
+	}
+}
+
+func TestUnquoteErrors(t *testing.T) {
+	for i, tc := range []struct {
+		lit  string
+		kind matchertext.ErrorKind
+		ofs  int64
+	}{
+		{``, ExpectedQuote, 0},
+		{`x`, ExpectedQuote, 0},
+		{`"abc`, UnterminatedLiteral, 4},
+		{"\"a\nb\"", UnterminatedLiteral, 2},
+		{`"a\`, UnterminatedLiteral, 3},
+		{`"ab"c`, ExpectedEnd, 4},
+		{`"\q"`, InvalidEscape, 2},
+		{`"\x4"`, InvalidEscape, 4},
+		{`"\o(]"`, InvalidEscape, 4},
+		{`"\777"`, InvalidEscape, 4},
+		{`"\uD800"`, InvalidEscape, 6},
+		{`"\[abc"`, matchertext.UnmatchedOpener, 2},
+		{`"\[a)]"`, matchertext.MismatchedCloser, 2},
+	} {
+		_, err := Unquote(tc.lit)
+		var se *matchertext.SyntaxError
+		if !errors.As(err, &se) || se.Kind() != tc.kind ||
+			se.Offset() != tc.ofs {
+			t.Errorf("%v %q: got error %v, expected %v at %v",
+				i, tc.lit, err, tc.kind, tc.ofs)
+		}
+	}
+}
+
+func TestExtract(t *testing.T) {
+	for i, tc := range []struct {
+		src        string
+		start, end int
+	}{
+		{`x = "abc";`, 4, 8},
+		{`f('a', "b")`, 2, 4},
+		{`f('a', "b")`, 7, 9},
+		{`s = "\["quoted"\]";`, 4, 17},
+		{`s = "\q\"";`, 4, 9},
+		{"s = \"\\[line\nbreak]\"", 4, 18},
+		{`s = "a" + "\["]"`, 10, 15},
+	} {
+		end, err := Extract([]byte(tc.src), tc.start)
+		if err != nil || end != tc.end {
+			t.Errorf("%v %q at %v: got %v, %v, expected %v",
+				i, tc.src, tc.start, end, err, tc.end)
+		}
+	}
+	for i, tc := range []struct {
+		src   string
+		start int
+		kind  matchertext.ErrorKind
+	}{
+		{`x = "abc`, 4, UnterminatedLiteral},
+		{`x = abc`, 4, ExpectedQuote},
+		{`x = "a"`, 10, ExpectedQuote},
+		{`x = "\[a"`, 4, matchertext.UnmatchedOpener},
+	} {
+		end, err := Extract([]byte(tc.src), tc.start)
+		if end != -1 || !errors.Is(err, tc.kind) {
+			t.Errorf("%v %q at %v: got %v, %v, expected %v",
+				i, tc.src, tc.start, end, err, tc.kind)
+		}
+	}
+}
+
+func TestQuote(t *testing.T) {
+	for i, tc := range []struct{ val, std, mt string }{
+		{"", `""`, `""`},
+		{"hello", `"hello"`, `"hello"`},
+		{"f(x)", `"f(x)"`, `"f(x)"`},
+		{"f(x", `"f(x"`, `"f\o()x"`},
+		{`"'\`, `"\"'\\"`, `"\["'\]"`},
+		{"a\nb(", `"a\nb("`, `"a\nb\o()"`},
+		{"\x00\x01z\x7f", `"\000\001z\177"`, `"\000\001z\177"`},
+		{"é\u00a0\xff", `"é\u00a0\xff"`, `"é\u00a0\xff"`},
+		{`say "[" or "]"`, `"say \"[\" or \"]\""`, `"\[say "[" or "]"]"`},
+		{`say "["`, `"say \"[\""`, `"say \"\o[]\""`},
+		{`say "(x)"`, `"say \"(x)\""`, `"\[say "(x)"]"`},
+	} {
+		if std := Quote(tc.val); std != tc.std {
+			t.Errorf("%v %q: Quote gave %v, expected %v",
+				i, tc.val, std, tc.std)
+		}
+		if mt := QuoteMatchertext(tc.val); mt != tc.mt {
+			t.Errorf("%v %q: QuoteMatchertext gave %v, expected %v",
+				i, tc.val, mt, tc.mt)
+		}
+		for _, lit := range []string{tc.std, tc.mt} {
+			if val, err := Unquote(lit); err != nil || val != tc.val {
+				t.Errorf("%v %v: unquoted as %q, %v", i, lit, val, err)
+			}
+		}
+		if std, err := Standard(tc.mt); err != nil || std != tc.std {
+			t.Errorf("%v %v: standardized as %v, %v", i, tc.mt, std, err)
+		}
+	}
+}
+
+// Quoted literals must round-trip, agree with Go's own unquoting,
+// and be valid matchertext if produced by QuoteMatchertext.
+func TestQuoteRandom(t *testing.T) {
+	rng := rand.New(rand.NewSource(22))
+	chars := []string{"a", "(", ")", "[", "]", "{", "}", `"`, `'`, `\`,
+		"\n", "\x00", "\x7f", "\xff", "é", "\u2028"}
+	for i := 0; i < 1000; i++ {
+		var val string
+		for range rng.Intn(12) {
+			val += chars[rng.Intn(len(chars))]
+		}
+		std := Quote(val)
+		if goval, err := strconv.Unquote(std); err != nil || goval != val {
+			t.Errorf("%q: Go unquoted %v as %q, %v", val, std, goval, err)
+		}
+		mt := QuoteMatchertext(val)
+		if um, _ := matchertext.UnmatchedOffsets(
+			bytes.NewReader([]byte(mt))); len(um) != 0 {
+			t.Errorf("%q: %v has unmatched matchers at %v", val, mt, um)
+		}
+		for _, lit := range []string{std, mt} {
+			if v, err := Unquote(lit); err != nil || v != val {
+				t.Errorf("%q: %v unquoted as %q, %v", val, lit, v, err)
+			}
+		}
+	}
+}
@@ -2,6 +2,7 @@ package ast
 
 import (
 	"io"
+	"strconv"
 
 	"github.com/dedis/matchertext/go/matchertext"
 )
@@ -34,6 +35,11 @@ type MatcherTransformer struct {
 	// If nil, defaults to producing decimal numeric character references
 	// for maximum compatibility (at the cost of ugliness).
 	Escaper func(b byte) string
+
+	// Config determines which matcher pairs must match.
+	// If nil, defaults to the standard matchertext configuration.
+	// A custom Escaper must handle every matcher of the configuration.
+	Config *matchertext.Config
 }
 
 func (mt *MatcherTransformer) Transform(ns []Node) ([]Node, error) {
@@ -44,8 +50,14 @@ func (mt *MatcherTransformer) Transform(ns []Node) ([]Node, error) {
 		esc = defaultEscaper
 	}
 
+	// Determine the matchertext configuration to check against
+	cfg := mt.Config
+	if cfg == nil {
+		cfg = matchertext.Standard
+	}
+
 	// Identify the unmatched literal matchers, if any, we need to escape
-	os, err := matchertext.UnmatchedOffsets(newTextReader(ns))
+	os, err := cfg.UnmatchedOffsets(newTextReader(ns))
 	if err != nil {
 		return nil, err
 	}
@@ -112,8 +124,8 @@ func defaultEscaper(b byte) string {
 		return "#123"
 	case '}':
 		return "#125"
-	default:
-		panic("Escaper argument must be a matcher")
+	default: // a matcher of some non-standard configuration
+		return "#" + strconv.Itoa(int(b))
 	}
 }
 
@@ -2,6 +2,8 @@ package ast
 
 import (
 	"testing"
+
+	"github.com/dedis/matchertext/go/matchertext"
 )
 
 type matcherTransformerTest struct {
@@ -70,3 +72,18 @@ func TestMatcherTransformer(t *testing.T) {
 }
 
 // XXX test alternate escaper functions
+
+func TestMatcherTransformerConfig(t *testing.T) {
+	// Only curly braces must match in the Braces configuration
+	xform := &MatcherTransformer{Config: matchertext.Braces}
+	ins := []Node{NewText("[0,1) {x}} ([y")}
+	ons, err := xform.Transform(ins)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	exp := []Node{NewText("[0,1) {x}"), NewReference("#125"),
+		NewText(" ([y")}
+	if !Equal(ons, exp) {
+		t.Errorf("expected %v got %v", exp, ons)
+	}
+}
@@ -9,8 +9,11 @@ import (
 	"github.com/dedis/matchertext/go/markup/xml"
 )
 
+// TreeWriter writes a markup AST to an output stream in HTML syntax.
 type TreeWriter struct {
 	w util.AtomWriter
+
+	bracket bool // write attribute values as [matchertext] where possible
 }
 
 // NewTreeWriter creates and returns a new encoder that writes output to w.
@@ -18,6 +21,19 @@ func NewTreeWriter(w io.Writer) *TreeWriter {
 	return &TreeWriter{w: util.ToAtomWriter(w)}
 }
 
+// WithBracketAttributes makes e write each attribute value
+// that consists only of text forming valid matchertext
+// in the bracket-quoted form name=[value] of extended HTML,
+// rather than name="value", and returns e.
+// Script code in an event handler attribute such as onclick
+// may then be written exactly as it would appear in a script element.
+// Values that contain references or are not valid matchertext
+// are still written in double quotes.
+func (e *TreeWriter) WithBracketAttributes() *TreeWriter {
+	e.bracket = true
+	return e
+}
+
 // WriteAST writes a slice of markup AST nodes to the encoder's output.
 func (e *TreeWriter) WriteAST(ns []ast.Node) (err error) {
 
@@ -108,6 +124,14 @@ func (e *TreeWriter) element(elt ast.Element) (err error) {
 		if err := e.w.WriteByte('='); err != nil {
 			return err
 		}
+		if e.bracket {
+			if s, ok := xml.BracketValue(value); ok {
+				if _, err := e.w.WriteString("[" + s + "]"); err != nil {
+					return err
+				}
+				continue
+			}
+		}
 		if err := e.w.WriteByte('"'); err != nil {
 			return err
 		}
@@ -90,3 +90,36 @@ func TestEncoder(t *testing.T) {
 		}
 	}
 }
+
+// Attribute values written in bracket-quoted form, as in the matchertext paper
+var bracketTests = []encTest{
+	et("<button onclick=[okClicked()]>OK</button>", aElem("button",
+		aAttr("onclick", aText("okClicked()")), aText("OK"))),
+	et("<button onclick=[emitCharacter(\"'\")]>Emit Apostrophe</button>",
+		aElem("button",
+			aAttr("onclick", aText("emitCharacter(\"'\")")),
+			aText("Emit Apostrophe"))),
+	et("<button onclick=[show(\"it's done!\")]>OK</button>", aElem("button",
+		aAttr("onclick", aText("show(\"it's done!\")")), aText("OK"))),
+	et("<x y=[a<b&c] z=[]></x>", aElem("x",
+		aAttr("y", aText("a<"), aRawText("b&c")), aAttr("z"))),
+
+	// Values with references or unmatched matchers stay in quotes
+	et("<x y=\"a&amp;b&lt;\"></x>", aElem("x",
+		aAttr("y", aText("a&b"), aRef("lt")))),
+	et("<x y=\"f(\"></x>", aElem("x", aAttr("y", aText("f(")))),
+}
+
+func TestEncoderBrackets(t *testing.T) {
+	for i, et := range bracketTests {
+		sb := &strings.Builder{}
+		e := NewTreeWriter(sb).WithBracketAttributes()
+		if err := e.WriteAST(et.ast); err != nil {
+			t.Error(err.Error())
+		}
+		s := sb.String()
+		if s != et.out {
+			t.Errorf("%v: expected %v output %v", i, et.out, s)
+		}
+	}
+}
@@ -4,12 +4,12 @@ package minml
 var Entity = map[string]string{
 
 	// Matchertext convenience escapes
-	"(<)": "(",
-	"(>)": ")",
-	"[<]": "[",
-	"[>]": "]",
-	"{<}": "{",
-	"{>}": "}",
+	"(<)": "(", //matchertext:allow
+	"(>)": ")", //matchertext:allow
+	"[<]": "[", //matchertext:allow
+	"[>]": "]", //matchertext:allow
+	"{<}": "{", //matchertext:allow
+	"{>}": "}", //matchertext:allow
 
 	// Punctuation
 	//	"-":	"\u00AD",	// soft hyphen?
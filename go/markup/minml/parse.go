@@ -2,6 +2,7 @@ package minml
 
 import (
 	"bytes"
+	"context"
 	"io"
 
 	"github.com/dedis/matchertext/go/markup/xml"
@@ -101,12 +102,25 @@ func (p *Parser) SetReader(r io.Reader) {
 	p.mp.SetReader(r)
 }
 
+// SetLimits bounds the nesting depth, size, and number of matcher pairs
+// of the MinML input the parser accepts.
+// On exceeding any limit, the parser stops with a matchertext.LimitError.
+func (p *Parser) SetLimits(l matchertext.Limits) {
+	p.mp.Limits = l
+}
+
+// SetContext makes the parser periodically check context ctx
+// and stop with the context's error once it is done.
+func (p *Parser) SetContext(ctx context.Context) {
+	p.mp.Context = ctx
+}
+
 // ReadAll reads an entire stream of MinML markup until end-of-file (EOF).
 // Returns a non-nil error if anything goes wrong.
 func (p *Parser) ReadAll(hm HandlerMarkup) error {
 	e := p.ReadMarkup(hm)
 	if e == nil {
-		return p.syntaxError("expected end of file")
+		return p.syntaxError(ExpectedEOF)
 	}
 	if e != io.EOF {
 		return e
@@ -164,6 +178,12 @@ func (mh mHandler) Byte(b byte) error {
 	return mh.p.buf.WriteByte(b)
 }
 
+// Accumulate whole runs of bytes between matchers when we can.
+func (mh mHandler) Bytes(b []byte) error {
+	_, err := mh.p.buf.Write(b)
+	return err
+}
+
 // Handle a matching pair of openers/closers while parsing matchertext.
 // The non-matchers immediately preceding the opener is buffered in p.buf.
 func (mh mHandler) Open(o, c byte) (e error) {
@@ -468,7 +488,7 @@ func (ah aHandler) Byte(b byte) error {
 	// Ensure the attribute name is actually a valid XML name
 	name := p.buf.Bytes()
 	if !xml.IsName(name) {
-		return p.syntaxError("invalid attribute name")
+		return p.syntaxError(InvalidAttributeName)
 	}
 	p.buf.Reset() // consume the name
 
@@ -481,13 +501,13 @@ func (ah aHandler) Byte(b byte) error {
 }
 
 func (ah aHandler) Open(o, c byte) error {
-	return ah.p.syntaxError("attribute name expected")
+	return ah.p.syntaxError(ExpectedAttributeName)
 }
 
 // Handle any residual bytes in the buffer while parsing attributes
 func (p *Parser) aFlush() error {
 	if p.buf.Len() > 0 {
-		return p.syntaxError("attribute value expected")
+		return p.syntaxError(ExpectedAttributeValue)
 	}
 	return nil
 }
@@ -518,7 +538,7 @@ func (p *Parser) ReadAttribute(name []byte, ht HandlerText) error {
 			return e
 		}
 		if b != '}' && !xml.IsSpace(b) {
-			return p.syntaxError("end of attribute value expected")
+			return p.syntaxError(ExpectedAttributeEnd)
 		}
 	} else {
 		// Parse unquoted attribute value text
@@ -604,6 +624,11 @@ func (rh rHandler) Byte(b byte) error {
 	return rh.p.buf.WriteByte(b)
 }
 
+func (rh rHandler) Bytes(b []byte) error {
+	_, err := rh.p.buf.Write(b)
+	return err
+}
+
 func (rh rHandler) Open(o, c byte) (e error) {
 	p := rh.p
 
@@ -661,6 +686,16 @@ func (p *Parser) handleComment() (e error) {
 	return
 }
 
-func (p *Parser) syntaxError(msg string) *matchertext.SyntaxError {
-	return p.mp.SyntaxError(msg)
+// Kinds of syntax errors specific to MinML markup,
+// which the parser reports as matchertext.SyntaxError values.
+const (
+	ExpectedEOF            matchertext.ErrorKind = "expected end of file"
+	InvalidAttributeName   matchertext.ErrorKind = "invalid attribute name"
+	ExpectedAttributeName  matchertext.ErrorKind = "attribute name expected"
+	ExpectedAttributeValue matchertext.ErrorKind = "attribute value expected"
+	ExpectedAttributeEnd   matchertext.ErrorKind = "end of attribute value expected"
+)
+
+func (p *Parser) syntaxError(kind matchertext.ErrorKind) *matchertext.SyntaxError {
+	return p.mp.SyntaxErrorKind(kind, string(kind))
 }
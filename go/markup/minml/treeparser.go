@@ -1,9 +1,11 @@
 package minml
 
 import (
+	"context"
 	"io"
 
 	"github.com/dedis/matchertext/go/markup/ast"
+	"github.com/dedis/matchertext/go/matchertext"
 )
 
 // A TreeParser parses a MinML stream into an abstract syntax tree (AST).
@@ -31,6 +33,20 @@ func (d *TreeParser) WithTransformer(t Transformer) *TreeParser {
 	return d
 }
 
+// WithLimits bounds the resources d may spend parsing its input
+// as Parser.SetLimits does, and returns d.
+func (d *TreeParser) WithLimits(l matchertext.Limits) *TreeParser {
+	d.ap.p.SetLimits(l)
+	return d
+}
+
+// WithContext makes d stop parsing once context ctx is done
+// as Parser.SetContext does, and returns d.
+func (d *TreeParser) WithContext(ctx context.Context) *TreeParser {
+	d.ap.p.SetContext(ctx)
+	return d
+}
+
 // We use this private internal struct to avoid exposing
 // the parsing callbacks below in the public TreeParser type.
 type astParser struct {
@@ -1,10 +1,14 @@
 package minml
 
 import (
+	"bufio"
+	"context"
+	"errors"
 	"strings"
 	"testing"
 
 	"github.com/dedis/matchertext/go/markup/ast"
+	"github.com/dedis/matchertext/go/matchertext"
 )
 
 type testCase struct {
@@ -187,3 +191,71 @@ func TestParser(t *testing.T) {
 		}
 	}
 }
+
+// Parsing from a buffered reader uses the matchertext parser's fast path,
+// which must produce the same results.
+func TestParserBuffered(t *testing.T) {
+	for i, dt := range decodeTests {
+		r := bufio.NewReaderSize(strings.NewReader(dt.s), 16)
+		n, e := NewTreeParser(r).ParseAST()
+		if e != nil && dt.n != nil {
+			t.Errorf("%v '%v': %v", i, dt.s, e.Error())
+		} else if e == nil && dt.n == nil {
+			t.Errorf("%v '%v': expected error, got %v", i, dt.s, n)
+		} else if e == nil && dt.n != nil && !ast.Equal(n, dt.n) {
+			t.Errorf("%v '%v': wrong output %v", i, dt.s, n)
+		}
+	}
+}
+
+func TestParserErrorKinds(t *testing.T) {
+	tests := []struct {
+		s    string
+		kind error
+	}{
+		{"p{ a=[]> }[]", ExpectedAttributeEnd},
+		{"p{x}[]", ExpectedAttributeValue},
+		{"p{ [] }[]", ExpectedAttributeName},
+		{"p{1=x}[]", InvalidAttributeName},
+		{"a)", ExpectedEOF},
+		{"a(b", matchertext.UnmatchedOpener},
+		{"p{a=[x}[]", matchertext.MismatchedCloser},
+	}
+	for i, et := range tests {
+		_, e := NewTreeParser(strings.NewReader(et.s)).ParseAST()
+		if !errors.Is(e, et.kind) {
+			t.Errorf("%v '%v': expected %v, got %v", i, et.s, et.kind, e)
+		}
+	}
+}
+
+func TestParserLimits(t *testing.T) {
+	deep := strings.Repeat("p[", 1000) + strings.Repeat("]", 1000)
+	tests := []struct {
+		s      string
+		limits matchertext.Limits
+		err    error
+	}{
+		{deep, matchertext.Limits{MaxDepth: 1000}, nil},
+		{deep, matchertext.Limits{MaxDepth: 999}, matchertext.ErrMaxDepth},
+		{"a p{x=[y]}[z] b", matchertext.Limits{MaxPairs: 3}, nil},
+		{"a p{x=[y]}[z] b", matchertext.Limits{MaxPairs: 2},
+			matchertext.ErrMaxPairs},
+		{"a p[b] c", matchertext.Limits{MaxBytes: 5},
+			matchertext.ErrMaxBytes},
+	}
+	for i, lt := range tests {
+		d := NewTreeParser(strings.NewReader(lt.s)).WithLimits(lt.limits)
+		_, e := d.ParseAST()
+		if !errors.Is(e, lt.err) || (e == nil) != (lt.err == nil) {
+			t.Errorf("%v: expected %v, got %v", i, lt.err, e)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	d := NewTreeParser(strings.NewReader("a p[b] c")).WithContext(ctx)
+	if _, e := d.ParseAST(); !errors.Is(e, context.Canceled) {
+		t.Errorf("expected cancellation, got %v", e)
+	}
+}
@@ -155,7 +155,7 @@ func (e *TreeWriter) element(elt ast.Element) (err error) {
 
 			// write the attribute name and value opener
 			name, val := a.Attribute()
-			if err := e.strings(name, "=["); err != nil {
+			if err := e.strings(name, "=["); err != nil { //matchertext:allow
 				return err
 			}
 
@@ -179,9 +179,9 @@ func (e *TreeWriter) element(elt ast.Element) (err error) {
 			}
 
 			// write the close bracket and potential space
-			end := "]"
+			end := "]" //matchertext:allow
 			if i+1 < len(attrs) {
-				end = "] "
+				end = "] " //matchertext:allow
 			}
 			if err := e.strings(end); err != nil {
 				return err
@@ -61,8 +61,7 @@ func IsName(s []byte) bool {
 	return true
 }
 
-// IsNameString returns true if string s contains a valid XML Name.
-// XXX need?
+// isNameString returns true if string s contains a valid XML Name.
 func isNameString(s string) bool {
 	if len(s) == 0 {
 		return false
@@ -3,13 +3,19 @@ package xml
 import (
 	"fmt"
 	"io"
+	"strings"
 
 	"github.com/dedis/matchertext/go/internal/util"
 	"github.com/dedis/matchertext/go/markup/ast"
+	"github.com/dedis/matchertext/go/matchertext"
 )
 
+// TreeWriter writes a markup AST to an output stream in XML syntax.
 type TreeWriter struct {
 	w util.AtomWriter
+
+	mdata   bool // write raw text as MDATA sections where possible
+	bracket bool // write attribute values as [matchertext] where possible
 }
 
 // NewTreeWriter creates and returns a TreeWriter that writes output to w.
@@ -17,6 +23,19 @@ func NewTreeWriter(w io.Writer) *TreeWriter {
 	return &TreeWriter{w: util.ToAtomWriter(w)}
 }
 
+// WithMDATA makes e write raw text that is valid matchertext
+// as a matchertext section <![MDATA[...]]> rather than a CDATA section,
+// and returns e.
+// The MDATA section ends at the close bracket matching its open bracket,
+// so the raw text may contain ]]> sequences without splitting the section.
+// Raw text that is not valid matchertext is still written as CDATA.
+//
+//matchertext:allow
+func (e *TreeWriter) WithMDATA() *TreeWriter {
+	e.mdata = true
+	return e
+}
+
 // WriteAST writes a slice of markup AST nodes to the encoder's output.
 func (e *TreeWriter) WriteAST(ns []ast.Node) (err error) {
 
@@ -24,7 +43,7 @@ func (e *TreeWriter) WriteAST(ns []ast.Node) (err error) {
 		switch n := ns[i].(type) {
 
 		case ast.RawText: // Plain text sequence, raw or cooked
-			e.text(n.Text(), n.IsRaw(), EscBasic)
+			err = e.text(n.Text(), n.IsRaw(), EscBasic)
 
 		case ast.Text: // Plain (cooked) text sequence
 			err = e.text(n.Text(), false, EscBasic)
@@ -58,20 +77,37 @@ func (e *TreeWriter) text(s string, raw bool, esc Escaper) error {
 	return esc.WriteStringTo(e.w, s)
 }
 
-const rsRaw = "]]]]><![CDATA[>"
+const rsRaw = "]]]]><![CDATA[>" //matchertext:allow
+
+// WithBracketAttributes makes e write each attribute value
+// that consists only of text forming valid matchertext
+// in the bracket-quoted form name=[value] rather than name="value",
+// and returns e.
+// The value between the brackets is uninterpreted,
+// so it needs no escaping of quotes, ampersands, or angle brackets.
+// Values that contain references or are not valid matchertext
+// are still written in double quotes.
+func (e *TreeWriter) WithBracketAttributes() *TreeWriter {
+	e.bracket = true
+	return e
+}
 
 // Write raw text as a CDATA section
 func (e *TreeWriter) rawText(s string) error {
 	if s == "" {
 		return nil
 	}
+	if e.mdata && isMatchertext(s) {
+		return e.mdataText(s)
+	}
 
 	// Start a CDATA section
-	if _, err := e.w.WriteString("<![CDATA["); err != nil {
+	if _, err := e.w.WriteString("<![CDATA["); err != nil { //matchertext:allow
 		return err
 	}
 
 	// Write the section content, replacing ]]> terminator sequences
+	//matchertext:allow
 	l := 0
 	for i := 0; i <= len(s)-3; {
 		if s[i] == ']' && s[i+1] == ']' && s[i+2] == '>' {
@@ -92,16 +128,51 @@ func (e *TreeWriter) rawText(s string) error {
 			i++
 		}
 	}
-	_, err := e.w.WriteString(s[l:])
+	if _, err := e.w.WriteString(s[l:]); err != nil {
+		return err
+	}
 
 	// End the CDATA section
-	if _, err := e.w.WriteString("]]>"); err != nil {
+	_, err := e.w.WriteString("]]>") //matchertext:allow
+	return err
+}
+
+// Write raw text as an MDATA section, which needs no replacements
+func (e *TreeWriter) mdataText(s string) error {
+	if _, err := e.w.WriteString("<![MDATA["); err != nil { //matchertext:allow
 		return err
 	}
-
+	if _, err := e.w.WriteString(s); err != nil {
+		return err
+	}
+	_, err := e.w.WriteString("]]>") //matchertext:allow
 	return err
 }
 
+// BracketValue returns the text of attribute value val and true
+// if val may be written in the bracket-quoted form name=[value]:
+// that is, if it consists only of text forming valid matchertext.
+// The XML and HTML tree writers use it to decide
+// whether to write a value in that form.
+func BracketValue(val []ast.Node) (string, bool) {
+	var sb strings.Builder
+	for _, n := range val {
+		t, ok := n.(ast.Text)
+		if !ok {
+			return "", false
+		}
+		sb.WriteString(t.Text())
+	}
+	s := sb.String()
+	return s, isMatchertext(s)
+}
+
+// Return true if s is valid matchertext.
+func isMatchertext(s string) bool {
+	offs, _ := matchertext.UnmatchedOffsets(strings.NewReader(s))
+	return len(offs) == 0
+}
+
 // Write a reference to XML output
 func (e *TreeWriter) reference(name string) error {
 
@@ -144,6 +215,14 @@ func (e *TreeWriter) element(name string, attr []ast.Attribute,
 		if err := e.w.WriteByte('='); err != nil {
 			return err
 		}
+		if e.bracket {
+			if s, ok := BracketValue(val); ok {
+				if _, err := e.w.WriteString("[" + s + "]"); err != nil {
+					return err
+				}
+				continue
+			}
+		}
 		if err := e.w.WriteByte('"'); err != nil {
 			return err
 		}
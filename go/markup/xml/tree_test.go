@@ -91,3 +91,58 @@ func TestTreeWriter(t *testing.T) {
 		}
 	}
 }
+
+// Raw text written as MDATA sections, as in the matchertext paper
+var mdataTests = []encTest{
+	et("<![MDATA[abc]]>", aRawText("abc")),
+	et("<![MDATA[<b>bold</b>]]>", aRawText("<b>bold</b>")),
+	et("<![MDATA[<![CDATA[character data]]>]]>",
+		aRawText("<![CDATA[character data]]>")),
+	et("<![MDATA[<![MDATA[<![MDATA[double embedded]]>]]>]]>",
+		aRawText("<![MDATA[<![MDATA[double embedded]]>]]>")),
+	et("<code>example <![MDATA[a[i]]]> markup</code>", aElem("code",
+		aText("example "), aRawText("a[i]"), aText(" markup"))),
+
+	// Raw text that is not valid matchertext falls back to CDATA
+	et("<![CDATA[a]]]]><![CDATA[>b]]>", aRawText("a]]>b")),
+	et("<![CDATA[(]]>", aRawText("(")),
+}
+
+func TestTreeWriterMDATA(t *testing.T) {
+	for i, et := range mdataTests {
+		sb := &strings.Builder{}
+		e := NewTreeWriter(sb).WithMDATA()
+		if err := e.WriteAST(et.ast); err != nil {
+			t.Error(err.Error())
+		}
+		s := sb.String()
+		if s != et.out {
+			t.Errorf("%v: expected %v output %v", i, et.out, s)
+		}
+	}
+}
+
+// Attribute values written in bracket-quoted form
+var bracketTests = []encTest{
+	et("<button onclick=[show(\"it's done!\")]>OK</button>", aElem("button",
+		aAttr("onclick", aText("show(\"it's done!\")")), aText("OK"))),
+	et("<x y=[a<b&c>] z=[]/>", aElem("x",
+		aAttr("y", aText("a<"), aRawText("b&c>")), aAttr("z"))),
+	et("<x y=\"a&amp;b&lt;\"/>", aElem("x",
+		aAttr("y", aText("a&b"), aRef("lt")))),
+	et("<x y=\"f]\"/>", aElem("x", aAttr("y", aText("f]")))),
+}
+
+func TestTreeWriterBrackets(t *testing.T) {
+	for i, et := range bracketTests {
+		sb := &strings.Builder{}
+		e := NewTreeWriter(sb).WithBracketAttributes()
+		if err := e.WriteAST(et.ast); err != nil {
+			t.Error(err.Error())
+		}
+		s := sb.String()
+		if s != et.out {
+			t.Errorf("%v: expected %v output %v", i, et.out, s)
+		}
+	}
+}
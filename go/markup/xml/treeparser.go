@@ -0,0 +1,478 @@
+package xml
+
+import (
+	"io"
+	"strings"
+
+	"github.com/dedis/matchertext/go/markup/ast"
+	"github.com/dedis/matchertext/go/matchertext"
+)
+
+// Kinds of syntax errors in XML markup,
+// which the TreeParser reports as matchertext.SyntaxError values.
+const (
+	UnexpectedEOF          matchertext.ErrorKind = "unexpected end of file"
+	ExpectedName           matchertext.ErrorKind = "name expected"
+	ExpectedAttributeValue matchertext.ErrorKind = "quoted attribute value expected"
+	ExpectedTagEnd         matchertext.ErrorKind = "end of tag expected"
+	MismatchedEndTag       matchertext.ErrorKind = "mismatched end tag"
+	UnexpectedEndTag       matchertext.ErrorKind = "unexpected end tag"
+	InvalidReference       matchertext.ErrorKind = "invalid character reference"
+	InvalidSection         matchertext.ErrorKind = "CDATA or MDATA section expected"
+	InvalidComment         matchertext.ErrorKind = "invalid comment"
+)
+
+// A TreeParser parses an XML stream into an abstract syntax tree (AST).
+//
+// The TreeParser accepts both standard CDATA sections
+// and the matchertext sections <![MDATA[...]]> that TreeWriter.WithMDATA
+// produces, whose content extends to the close bracket
+// matching the section's open bracket and must be valid matchertext.
+// Either kind of section yields an ast.RawText node,
+// and the TreeParser joins adjacent sections into a single node,
+// so that a CDATA section split to contain a ]]> sequence
+// yields the original raw text.
+//
+// The TreeParser also accepts attribute values in the bracket-quoted form
+// name=[value] that TreeWriter.WithBracketAttributes produces,
+// whose uninterpreted value extends to the close bracket
+// matching the open bracket and must be valid matchertext.
+//
+// The TreeParser decodes the five predefined XML entity references
+// such as &lt; into ordinary text,
+// and yields an ast.Reference node for any other reference.
+// It skips processing instructions and document type declarations,
+// but does not otherwise validate the document against XML's rules.
+//
+//matchertext:allow
+type TreeParser struct {
+	p *matchertext.Parser
+}
+
+// NewTreeParser creates a TreeParser to parse input r.
+func NewTreeParser(r io.Reader) *TreeParser {
+	return &TreeParser{p: matchertext.NewParser(r)}
+}
+
+// ParseAST parses an XML stream
+// into an abstract syntax tree (AST) representation.
+func (d *TreeParser) ParseAST() ([]ast.Node, error) {
+	return d.content("")
+}
+
+// Characters that the predefined entity references represent
+var predefined = map[string]byte{
+	"amp": '&', "lt": '<', "gt": '>', "apos": '\'', "quot": '"',
+}
+
+// Parse markup content through the end tag of the element named name,
+// or through the end of input if name is empty.
+func (d *TreeParser) content(name string) (ns []ast.Node, err error) {
+	var text strings.Builder
+	flush := func() {
+		if text.Len() > 0 {
+			ns = append(ns, ast.NewText(text.String()))
+			text.Reset()
+		}
+	}
+	add := func(n ast.Node) {
+		// Join adjacent raw text sections
+		r, ok := n.(ast.RawText)
+		if ok && len(ns) > 0 {
+			if pr, pok := ns[len(ns)-1].(ast.RawText); pok && pr.IsRaw() {
+				n = ast.NewRawText(pr.Text() + r.Text())
+				ns = ns[:len(ns)-1]
+			}
+		}
+		ns = append(ns, n)
+	}
+
+	for {
+		b, err := d.p.ReadByte()
+		switch {
+		case err == io.EOF && name == "":
+			flush()
+			return ns, nil
+		case err == io.EOF:
+			return nil, d.syntaxError(UnexpectedEOF)
+		case err != nil:
+			return nil, err
+
+		case b == '&':
+			ref, err := d.reference()
+			if err != nil {
+				return nil, err
+			}
+			if c, ok := predefined[ref]; ok {
+				text.WriteByte(c)
+				continue
+			}
+			flush()
+			ns = append(ns, ast.NewReference(ref))
+			continue
+
+		case b != '<':
+			text.WriteByte(b)
+			continue
+		}
+
+		flush()
+		b, err = d.p.PeekByte()
+		if err != nil {
+			return nil, d.eof(err)
+		}
+		switch b {
+		case '/':
+			d.p.ReadByte()
+			return ns, d.endTag(name)
+
+		case '!':
+			d.p.ReadByte()
+			n, err := d.declaration()
+			if err != nil {
+				return nil, err
+			}
+			if n != nil {
+				add(n)
+			}
+
+		case '?':
+			if err := d.skipTo("?>"); err != nil {
+				return nil, err
+			}
+
+		default:
+			elt, err := d.element()
+			if err != nil {
+				return nil, err
+			}
+			add(elt)
+		}
+	}
+}
+
+// Parse the rest of an end tag following </,
+// which must match the start tag of the element named name.
+func (d *TreeParser) endTag(name string) error {
+	if name == "" {
+		return d.syntaxError(UnexpectedEndTag)
+	}
+	end, err := d.name()
+	if err != nil {
+		return err
+	}
+	if end != name {
+		return d.syntaxError(MismatchedEndTag)
+	}
+	if err := d.skipSpace(); err != nil {
+		return err
+	}
+	return d.expect('>', ExpectedTagEnd)
+}
+
+// Parse the rest of a markup declaration following <!,
+// returning a Comment node for a comment, a RawText node for a section,
+// or nil for a skipped declaration such as a document type
+// or an empty section.
+func (d *TreeParser) declaration() (ast.Node, error) {
+	b, err := d.p.ReadByte()
+	if err != nil {
+		return nil, d.eof(err)
+	}
+	switch b {
+	case '-':
+		if err := d.expect('-', InvalidComment); err != nil {
+			return nil, err
+		}
+		s, err := d.readTo("--")
+		if err != nil {
+			return nil, err
+		}
+		if err := d.expect('>', InvalidComment); err != nil {
+			return nil, err
+		}
+		return ast.NewComment(s), nil
+
+	case '[':
+		s, err := d.section()
+		if err != nil || s == "" {
+			return nil, err
+		}
+		return ast.NewRawText(s), nil
+	}
+
+	// Skip a declaration such as <!DOCTYPE ...>,
+	// including any bracketed internal subset.
+	for depth := 0; ; {
+		switch b {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case '>':
+			if depth <= 0 {
+				return nil, nil
+			}
+		}
+		if b, err = d.p.ReadByte(); err != nil {
+			return nil, d.eof(err)
+		}
+	}
+}
+
+// Parse the rest of a CDATA or MDATA section following <![,
+// returning its raw text.
+//
+//matchertext:allow
+func (d *TreeParser) section() (string, error) {
+	var kind strings.Builder
+	for {
+		b, err := d.p.PeekByte()
+		if err != nil {
+			return "", d.eof(err)
+		}
+		if b == '[' {
+			break
+		}
+		if kind.Len() == len("CDATA") {
+			return "", d.syntaxError(InvalidSection)
+		}
+		d.p.ReadByte()
+		kind.WriteByte(b)
+	}
+
+	switch kind.String() {
+	case "CDATA":
+		d.p.ReadByte()
+		return d.readTo("]]>") //matchertext:allow
+
+	case "MDATA":
+		// The embedded matchertext ends at the matching close bracket,
+		// which must be followed by the rest of the section terminator.
+		var s strings.Builder
+		if _, _, err := d.p.Extract(&s); err != nil {
+			return "", err
+		}
+		if err := d.expect(']', InvalidSection); err != nil {
+			return "", err
+		}
+		if err := d.expect('>', InvalidSection); err != nil {
+			return "", err
+		}
+		return s.String(), nil
+	}
+	return "", d.syntaxError(InvalidSection)
+}
+
+// Parse the rest of an element following the < of its start tag.
+func (d *TreeParser) element() (ast.Element, error) {
+	name, err := d.name()
+	if err != nil {
+		return nil, err
+	}
+
+	var ns []ast.Node
+	for {
+		if err := d.skipSpace(); err != nil {
+			return nil, err
+		}
+		b, err := d.p.PeekByte()
+		if err != nil {
+			return nil, d.eof(err)
+		}
+		switch b {
+		case '/':
+			d.p.ReadByte()
+			if err := d.expect('>', ExpectedTagEnd); err != nil {
+				return nil, err
+			}
+			return ast.NewElement(name, ns...), nil
+
+		case '>':
+			d.p.ReadByte()
+			content, err := d.content(name)
+			if err != nil {
+				return nil, err
+			}
+			return ast.NewElement(name, append(ns, content...)...), nil
+		}
+
+		attr, err := d.attribute()
+		if err != nil {
+			return nil, err
+		}
+		ns = append(ns, attr)
+	}
+}
+
+// Parse an attribute of the form name="value", name='value',
+// or name=[value].
+func (d *TreeParser) attribute() (ast.Attribute, error) {
+	name, err := d.name()
+	if err != nil {
+		return nil, err
+	}
+	if err := d.skipSpace(); err != nil {
+		return nil, err
+	}
+	if err := d.expect('=', ExpectedAttributeValue); err != nil {
+		return nil, err
+	}
+	if err := d.skipSpace(); err != nil {
+		return nil, err
+	}
+	if b, err := d.p.PeekByte(); err == nil && b == '[' {
+		var s strings.Builder
+		if _, _, err := d.p.Extract(&s); err != nil {
+			return nil, err
+		}
+		if s.Len() == 0 {
+			return ast.NewAttribute(name), nil
+		}
+		return ast.NewAttribute(name, ast.NewText(s.String())), nil
+	}
+	q, err := d.p.ReadByte()
+	if err != nil {
+		return nil, d.eof(err)
+	}
+	if q != '"' && q != '\'' {
+		return nil, d.syntaxError(ExpectedAttributeValue)
+	}
+
+	var ns []ast.Node
+	var text strings.Builder
+	for {
+		b, err := d.p.ReadByte()
+		switch {
+		case err != nil:
+			return nil, d.eof(err)
+
+		case b == q:
+			if text.Len() > 0 {
+				ns = append(ns, ast.NewText(text.String()))
+			}
+			return ast.NewAttribute(name, ns...), nil
+
+		case b == '&':
+			ref, err := d.reference()
+			if err != nil {
+				return nil, err
+			}
+			if c, ok := predefined[ref]; ok {
+				text.WriteByte(c)
+				break
+			}
+			if text.Len() > 0 {
+				ns = append(ns, ast.NewText(text.String()))
+				text.Reset()
+			}
+			ns = append(ns, ast.NewReference(ref))
+
+		default:
+			text.WriteByte(b)
+		}
+	}
+}
+
+// Parse the rest of a reference following &, through its semicolon,
+// and return the name or number between them.
+func (d *TreeParser) reference() (string, error) {
+	var ref strings.Builder
+	for {
+		b, err := d.p.ReadByte()
+		if err != nil {
+			return "", d.eof(err)
+		}
+		if b == ';' {
+			break
+		}
+		if b == '<' || b == '&' || IsSpace(b) {
+			return "", d.syntaxError(InvalidReference)
+		}
+		ref.WriteByte(b)
+	}
+	if !IsReference([]byte(ref.String())) {
+		return "", d.syntaxError(InvalidReference)
+	}
+	return ref.String(), nil
+}
+
+// Parse an XML name.
+func (d *TreeParser) name() (string, error) {
+	var name strings.Builder
+	for {
+		b, err := d.p.PeekByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		if b < 0x80 && !IsNameChar(rune(b)) {
+			break
+		}
+		d.p.ReadByte()
+		name.WriteByte(b)
+	}
+	if !isNameString(name.String()) {
+		return "", d.syntaxError(ExpectedName)
+	}
+	return name.String(), nil
+}
+
+// Skip any whitespace.
+func (d *TreeParser) skipSpace() error {
+	for {
+		b, err := d.p.PeekByte()
+		if err != nil {
+			return d.eof(err)
+		}
+		if !IsSpace(b) {
+			return nil
+		}
+		d.p.ReadByte()
+	}
+}
+
+// Read the byte b, or report a syntax error of the given kind.
+func (d *TreeParser) expect(b byte, kind matchertext.ErrorKind) error {
+	c, err := d.p.ReadByte()
+	if err != nil {
+		return d.eof(err)
+	}
+	if c != b {
+		return d.syntaxError(kind)
+	}
+	return nil
+}
+
+// Read text through the terminator term, returning the text before it.
+func (d *TreeParser) readTo(term string) (string, error) {
+	var s strings.Builder
+	for !strings.HasSuffix(s.String(), term) {
+		b, err := d.p.ReadByte()
+		if err != nil {
+			return "", d.eof(err)
+		}
+		s.WriteByte(b)
+	}
+	return strings.TrimSuffix(s.String(), term), nil
+}
+
+// Skip text through the terminator term.
+func (d *TreeParser) skipTo(term string) error {
+	_, err := d.readTo(term)
+	return err
+}
+
+// Convert an unexpected end of input into a syntax error.
+func (d *TreeParser) eof(err error) error {
+	if err == io.EOF {
+		return d.syntaxError(UnexpectedEOF)
+	}
+	return err
+}
+
+func (d *TreeParser) syntaxError(kind matchertext.ErrorKind) *matchertext.SyntaxError {
+	return d.p.SyntaxErrorKind(kind, string(kind))
+}
@@ -0,0 +1,158 @@
+package xml
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/dedis/matchertext/go/markup/ast"
+	"github.com/dedis/matchertext/go/matchertext"
+)
+
+type parseTest struct {
+	s string     // XML string to be parsed
+	n []ast.Node // AST that it should parse to
+}
+
+func pt(s string, ns ...ast.Node) parseTest {
+	return parseTest{s, ns}
+}
+
+var parseTests = []parseTest{
+
+	// Text and references
+	pt(""),
+	pt("abc", aText("abc")),
+	pt("a&lt;b&gt;c&amp;d&apos;e&quot;f", aText("a<b>c&d'e\"f")),
+	pt("a&hello;b&#123;", aText("a"), aRef("hello"), aText("b"),
+		aRef("#123")),
+
+	// Raw text sections
+	pt("<![CDATA[<mark></up>]]>", aRawText("<mark></up>")),
+	pt("<![CDATA[]]]]><![CDATA[>]]>", aRawText("]]>")),
+	pt("<![CDATA[]]>"),
+	pt("<![MDATA[<b>bold</b>]]>", aRawText("<b>bold</b>")),
+	pt("<![MDATA[<![CDATA[character data]]>]]>",
+		aRawText("<![CDATA[character data]]>")),
+	pt("<![MDATA[<![MDATA[<![MDATA[double embedded]]>]]>]]>",
+		aRawText("<![MDATA[<![MDATA[double embedded]]>]]>")),
+	pt("<![MDATA[a]]><![CDATA[b]]>x", aRawText("ab"), aText("x")),
+
+	// Elements and attributes
+	pt("<p/>", aElem("p")),
+	pt("<p></p>", aElem("p")),
+	pt("<i><b>nested</b></i>", aElem("i", aElem("b", aText("nested")))),
+	pt("<a href=\"foo\">link</a >", aElem("a",
+		aAttr("href", aText("foo")), aText("link"))),
+	pt("<img src='foo' alt = \"b&amp;r\" />", aElem("img",
+		aAttr("src", aText("foo")), aAttr("alt", aText("b&r")))),
+	pt("<x y=\"a&ref;b\" z=''/>", aElem("x",
+		aAttr("y", aText("a"), aRef("ref"), aText("b")), aAttr("z"))),
+
+	// Bracket-quoted attribute values
+	pt("<button onclick=[show(\"it's done!\")]>OK</button>",
+		aElem("button", aAttr("onclick", aText("show(\"it's done!\")")),
+			aText("OK"))),
+	pt("<x y=[a<b&amp;[c]] z = []/>", aElem("x",
+		aAttr("y", aText("a<b&amp;[c]")), aAttr("z"))),
+
+	// Comments, processing instructions, and declarations
+	pt("<!-- note -->", aComment(" note ")),
+	pt("<?xml version=\"1.0\"?><!DOCTYPE r [<!ENTITY e \"x\">]><r/>",
+		aElem("r")),
+}
+
+func TestTreeParser(t *testing.T) {
+	for i, pt := range parseTests {
+		ns, err := NewTreeParser(strings.NewReader(pt.s)).ParseAST()
+		if err != nil {
+			t.Errorf("%v %q: %v", i, pt.s, err)
+		} else if !ast.Equal(ns, pt.n) {
+			t.Errorf("%v %q: got %v expected %v", i, pt.s, ns, pt.n)
+		}
+	}
+}
+
+func TestTreeParserError(t *testing.T) {
+	for i, tc := range []struct {
+		s    string
+		kind matchertext.ErrorKind
+	}{
+		{"<p>", UnexpectedEOF},
+		{"<p></q>", MismatchedEndTag},
+		{"</p>", UnexpectedEndTag},
+		{"<p x>", ExpectedAttributeValue},
+		{"<p x=y/>", ExpectedAttributeValue},
+		{"<p/ >", ExpectedTagEnd},
+		{"<1/>", ExpectedName},
+		{"a&b c;", InvalidReference},
+		{"a&;", InvalidReference},
+		{"<!-- a -- b -->", InvalidComment},
+		{"<![XDATA[x]]>", InvalidSection},
+		{"<![MDATA[x]>", InvalidSection},
+		{"<![MDATA[(]]>", matchertext.MismatchedCloser},
+		{"<![CDATA[x", UnexpectedEOF},
+		{"<p x=[(]]/>", matchertext.MismatchedCloser},
+		{"<p x=[a", matchertext.UnmatchedOpener},
+	} {
+		_, err := NewTreeParser(strings.NewReader(tc.s)).ParseAST()
+		var se *matchertext.SyntaxError
+		if !errors.As(err, &se) || se.Kind() != tc.kind {
+			t.Errorf("%v %q: got %v, expected %v", i, tc.s, err, tc.kind)
+		}
+	}
+}
+
+// Raw text written with or without MDATA sections must parse back
+func TestTreeParserRoundTrip(t *testing.T) {
+	for i, s := range []string{
+		"abc", "]]>", "a]]>b]]>c", "<![CDATA[x]]>", "(", "]",
+		"<![MDATA[<![MDATA[x]]>]]>",
+	} {
+		for _, mdata := range []bool{false, true} {
+			ns := []ast.Node{aElem("p", aRawText(s))}
+			sb := &strings.Builder{}
+			w := NewTreeWriter(sb)
+			if mdata {
+				w.WithMDATA()
+			}
+			if err := w.WriteAST(ns); err != nil {
+				t.Fatal(err)
+			}
+			got, err := NewTreeParser(strings.NewReader(sb.String())).
+				ParseAST()
+			if err != nil || !ast.Equal(got, ns) {
+				t.Errorf("%v %q: wrote %q, parsed %v, %v",
+					i, s, sb.String(), got, err)
+			}
+		}
+	}
+}
+
+// Attribute values written with or without brackets must parse back
+func TestTreeParserAttributeRoundTrip(t *testing.T) {
+	for i, s := range []string{
+		"", "abc", "it's \"done\"", "a<b>&c", "f(x[i])", "(", "]",
+	} {
+		for _, bracket := range []bool{false, true} {
+			ns := []ast.Node{aElem("p", aAttr("x", aText(s)))}
+			if s == "" {
+				ns = []ast.Node{aElem("p", aAttr("x"))}
+			}
+			sb := &strings.Builder{}
+			w := NewTreeWriter(sb)
+			if bracket {
+				w.WithBracketAttributes()
+			}
+			if err := w.WriteAST(ns); err != nil {
+				t.Fatal(err)
+			}
+			got, err := NewTreeParser(strings.NewReader(sb.String())).
+				ParseAST()
+			if err != nil || !ast.Equal(got, ns) {
+				t.Errorf("%v %q: wrote %q, parsed %v, %v",
+					i, s, sb.String(), got, err)
+			}
+		}
+	}
+}
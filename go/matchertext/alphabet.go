@@ -0,0 +1,181 @@
+package matchertext
+
+import (
+	"fmt"
+	"unicode"
+)
+
+// Policy is a configuration bitmask selecting optional restrictions
+// on the alphabet a Parser accepts,
+// beyond the alphabet of its matchertext configuration.
+// The zero Policy accepts any byte the configuration allows.
+type Policy int
+
+const (
+	RejectNUL         Policy = 1 << iota // Reject the NUL byte
+	RejectControl                        // Reject controls but tab, CR, LF
+	RejectNonGraphic                     // Reject spaces and all controls
+	RejectInvalidUTF8                    // Reject ill-formed UTF-8
+
+	// PolicyURI accepts only graphical characters in valid UTF-8,
+	// as appropriate for matchertext embedded in URIs.
+	PolicyURI = RejectNonGraphic | RejectInvalidUTF8
+
+	// Policies that require decoding UTF-8 sequences into runes
+	policyRunes = RejectControl | RejectNonGraphic | RejectInvalidUTF8
+)
+
+// utf8State tracks a multibyte UTF-8 sequence in progress.
+type utf8State struct {
+	need   int   // continuation bytes still needed
+	lo, hi byte  // acceptable range of the next continuation byte
+	r      rune  // rune decoded so far
+	ofs    int64 // byte offset of the sequence's leading byte
+	line   int   // line number of the leading byte
+	col    int   // column number of the leading byte
+}
+
+// Check byte b, just read at the parser's current position,
+// against the configured alphabet and alphabet policy.
+// Reports any violations via the parser's error handler.
+func (p *Parser) checkByte(b byte) error {
+	if !p.config().Allows(b) {
+		if b < 0x80 && p.u.need > 0 {
+			// The ASCII byte b ends any UTF-8 sequence in progress,
+			// which precedes b and so is reported first.
+			p.u.need = 0
+			err := p.badUTF8("invalid UTF-8 sequence", p.ofs)
+			if err != nil {
+				return err
+			}
+		}
+		err := p.handleError(p.SyntaxErrorKind(DisallowedByte, fmt.Sprintf(
+			"disallowed byte %#02x", b)))
+		if err != nil {
+			return err
+		}
+		if b < 0x80 || p.Policy&policyRunes == 0 {
+			return nil // don't report the same byte twice
+		}
+	}
+
+	pol := p.Policy
+	if pol&policyRunes == 0 {
+		if b == 0 && pol&RejectNUL != 0 {
+			return p.handleError(p.SyntaxErrorKind(DisallowedByte,
+				"disallowed NUL byte"))
+		}
+		return nil
+	}
+
+	// Continue any multibyte UTF-8 sequence in progress
+	if p.u.need > 0 {
+		if b >= p.u.lo && b <= p.u.hi {
+			p.u.r = p.u.r<<6 | rune(b&0x3F)
+			p.u.lo, p.u.hi = 0x80, 0xBF
+			p.u.need--
+			if p.u.need == 0 {
+				return p.checkRune(p.u.r)
+			}
+			return nil
+		}
+
+		// The sequence is ill-formed: report it at its leading byte,
+		// then treat b as the start of whatever comes next.
+		p.u.need = 0
+		if err := p.badUTF8("invalid UTF-8 sequence", p.ofs); err != nil {
+			return err
+		}
+	}
+
+	// Start a new UTF-8 sequence at the current position
+	p.u.ofs, p.u.line, p.u.col = p.ofs, p.line, p.col
+	p.u.lo, p.u.hi = 0x80, 0xBF
+	switch {
+	case b < 0x80:
+		return p.checkASCII(b)
+	case b < 0xC2:
+		return p.badUTF8("invalid UTF-8 sequence", p.ofs+1)
+	case b < 0xE0:
+		p.u.need, p.u.r = 1, rune(b&0x1F)
+	case b < 0xF0:
+		p.u.need, p.u.r = 2, rune(b&0x0F)
+		if b == 0xE0 {
+			p.u.lo = 0xA0 // reject overlong encodings
+		} else if b == 0xED {
+			p.u.hi = 0x9F // reject surrogates
+		}
+	case b < 0xF5:
+		p.u.need, p.u.r = 3, rune(b&0x07)
+		if b == 0xF0 {
+			p.u.lo = 0x90 // reject overlong encodings
+		} else if b == 0xF4 {
+			p.u.hi = 0x8F // reject runes beyond U+10FFFF
+		}
+	default:
+		return p.badUTF8("invalid UTF-8 sequence", p.ofs+1)
+	}
+	return nil
+}
+
+// Check an ASCII byte b against the parser's alphabet policy.
+func (p *Parser) checkASCII(b byte) error {
+	pol := p.Policy
+	switch {
+	case b == 0 && pol&(RejectNUL|RejectControl|RejectNonGraphic) != 0:
+		return p.handleError(p.SyntaxErrorKind(DisallowedByte,
+			"disallowed NUL byte"))
+
+	case b > ' ' && b != 0x7F:
+		return nil // graphical
+
+	case pol&RejectNonGraphic != 0:
+		return p.handleError(p.SyntaxErrorKind(DisallowedByte, fmt.Sprintf(
+			"disallowed non-graphical byte %#02x", b)))
+
+	case pol&RejectControl != 0 && b != ' ' &&
+		b != '\t' && b != '\n' && b != '\r':
+		return p.handleError(p.SyntaxErrorKind(DisallowedByte, fmt.Sprintf(
+			"disallowed control code %#02x", b)))
+	}
+	return nil
+}
+
+// Check a completely-decoded non-ASCII rune against the alphabet policy.
+func (p *Parser) checkRune(r rune) error {
+	pol := p.Policy
+	switch {
+	case unicode.IsControl(r) && pol&(RejectControl|RejectNonGraphic) != 0:
+		return p.handleError(p.runeError(DisallowedRune, fmt.Sprintf(
+			"disallowed control character %U", r), p.ofs+1))
+
+	case !unicode.IsPrint(r) && pol&RejectNonGraphic != 0:
+		return p.handleError(p.runeError(DisallowedRune, fmt.Sprintf(
+			"disallowed non-graphical character %U", r), p.ofs+1))
+	}
+	return nil
+}
+
+// Report an ill-formed UTF-8 sequence ending before offset end
+// if the policy rejects them.
+func (p *Parser) badUTF8(msg string, end int64) error {
+	if p.Policy&RejectInvalidUTF8 == 0 {
+		return nil
+	}
+	return p.handleError(p.runeError(InvalidUTF8, msg, end))
+}
+
+// Check for a UTF-8 sequence left incomplete at end-of-file.
+func (p *Parser) checkEOF() error {
+	if p.u.need == 0 {
+		return nil
+	}
+	p.u.need = 0
+	return p.badUTF8("truncated UTF-8 sequence", p.ofs)
+}
+
+// Create a syntax error spanning from the start of the current UTF-8 sequence
+// to offset end.
+func (p *Parser) runeError(kind ErrorKind, msg string, end int64) *SyntaxError {
+	return &SyntaxError{kind, msg, p.u.ofs, end, p.u.line, p.u.col}
+}
@@ -0,0 +1,107 @@
+package matchertext
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+type alphabetTest struct {
+	cfg *Config
+	pol Policy
+	in  string
+	os  OffsetSlice // offsets of expected alphabet violations
+}
+
+var alphabetTests = []alphabetTest{
+	{nil, 0, "a\x00b\x01c\xff", nil},
+	{nil, RejectNUL, "a\x00b\x01(\x00)", OffsetSlice{1, 5}},
+	{nil, RejectControl, "a\tb\r\n c\x01d\x7F", OffsetSlice{7, 9}},
+	{nil, RejectControl, "x\u0085y z", OffsetSlice{1}},
+	{nil, RejectNonGraphic, "a b\tc(\x00)", OffsetSlice{1, 3, 6}},
+	{nil, RejectNonGraphic, "x y zé", OffsetSlice{1, 4}},
+	{nil, RejectInvalidUTF8, "héllo, 世界 \U0001F600", nil},
+	{nil, RejectInvalidUTF8, "a\xffb\x80c", OffsetSlice{1, 3}},
+	{nil, RejectInvalidUTF8, "a\xc3(b)", OffsetSlice{1}},
+	{nil, RejectInvalidUTF8, "\xe2\x82x\xe2\x82\xac", OffsetSlice{0}},
+	{nil, RejectInvalidUTF8, "\xc0\xaf\xe0\x80\xaf", // overlong
+		OffsetSlice{0, 1, 2, 3, 4}},
+	{nil, RejectInvalidUTF8, "\xed\xa0\x80", OffsetSlice{0, 1, 2}},
+	{nil, RejectInvalidUTF8, "\xf4\x90\x80\x80", // > U+10FFFF
+		OffsetSlice{0, 1, 2, 3}},
+	{nil, RejectInvalidUTF8, "ab\xf0\x9f\x98", OffsetSlice{2}}, // truncated
+	{nil, PolicyURI, "http://x/%[a b]", OffsetSlice{12}},
+	{Graphic, 0, "a b\n(c)", OffsetSlice{1, 3}},
+	{Graphic, RejectControl, "a\x01b", OffsetSlice{1}},
+	{Graphic, RejectInvalidUTF8, "\xc3 \xa9", OffsetSlice{0, 1, 2}},
+}
+
+func TestAlphabet(t *testing.T) {
+	for i, at := range alphabetTests {
+		var os OffsetSlice
+		h := &testHandler{}
+		h.p = NewParser(strings.NewReader(at.in))
+		h.p.Config = at.cfg
+		h.p.Policy = at.pol
+		h.p.HandleError = func(err error) error {
+			se, ok := err.(*SyntaxError)
+			if !ok {
+				t.Errorf("%v: unexpected error %v", i, err)
+				return err
+			}
+			os = append(os, se.Offset())
+			return nil
+		}
+		if err := h.p.ReadAll(h); err != nil {
+			t.Errorf("%v %q: %v", i, at.in, err.Error())
+		}
+		if !eqOffsetSlice(os, at.os) {
+			t.Errorf("%v %q: expected %v got %v", i, at.in, at.os, os)
+		}
+
+		// Accepted violations must be passed through as normal bytes
+		want := strings.NewReplacer("(", "<", ")", ">",
+			"[", "<", "]", ">").Replace(at.in)
+		if h.sb.String() != want {
+			t.Errorf("%v %q: output %q", i, at.in, h.sb.String())
+		}
+	}
+}
+
+func TestAlphabetStop(t *testing.T) {
+	p := NewParser(strings.NewReader("ab\ncd\x00ef"))
+	p.Policy = RejectNUL
+	err := p.ReadAll(&testHandler{p: p})
+	se, ok := err.(*SyntaxError)
+	if !ok {
+		t.Fatalf("expected syntax error, got %v", err)
+	}
+	if line, col := se.Position(); se.Offset() != 5 || line != 2 || col != 3 {
+		t.Errorf("wrong error position %v", se.Error())
+	}
+}
+
+// Peeking at a disallowed byte must report it without consuming it.
+func TestAlphabetPeek(t *testing.T) {
+	p := NewParser(strings.NewReader("a\x00b"))
+	p.Policy = RejectNUL
+	for i, want := range []byte("a\x00\x00b") {
+		var b byte
+		var err error
+		if i == 1 {
+			b, err = p.PeekByte()
+			if _, ok := err.(*SyntaxError); !ok || b != 0 {
+				t.Errorf("PeekByte returned %q, %v", b, err)
+			}
+			continue
+		}
+		b, err = p.ReadByte()
+		if err != nil || b != want {
+			t.Errorf("%v: ReadByte returned %q, %v, expected %q",
+				i, b, err, want)
+		}
+	}
+	if _, err := p.ReadByte(); err != io.EOF {
+		t.Errorf("expected EOF, got %v", err)
+	}
+}
@@ -0,0 +1,269 @@
+// Package analyze measures matchertext violations in existing source code,
+// to help estimate the cost of adopting the matchertext discipline
+// in a programming language.
+//
+// An Analyzer finds the unmatched matchers in each source file
+// using matchertext.UnmatchedOffsets,
+// then uses a lightweight lexer for the file's language
+// to classify each one according to whether it appears
+// in a string literal, a comment, or elsewhere in the code.
+// Walking a source tree yields a Report summarizing these violations
+// per file, per language, and per category,
+// which WriteText and WriteJSON present for people and programs.
+package analyze
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/dedis/matchertext/go/matchertext"
+)
+
+// Analyzer holds options controlling the analysis of source files.
+// The zero value is ready to use with default options.
+type Analyzer struct {
+	// Config is the matchertext configuration to check against.
+	// If nil, the Analyzer uses matchertext.Standard.
+	Config *matchertext.Config
+
+	// Languages lists the languages to analyze.
+	// A file whose extension matches none of them is skipped.
+	// If nil, the Analyzer uses the package-level Languages.
+	Languages []*Language
+
+	// Details enables recording the position of every violation
+	// in each FileReport, rather than only counts.
+	Details bool
+}
+
+// Counts holds the number of violations in each category.
+type Counts struct {
+	Code    int `json:"code"`
+	String  int `json:"string"`
+	Comment int `json:"comment"`
+}
+
+// Get returns the count for category c.
+func (n *Counts) Get(c Category) int {
+	switch c {
+	case Code:
+		return n.Code
+	case String:
+		return n.String
+	case Comment:
+		return n.Comment
+	}
+	return 0
+}
+
+// Add adds delta to the count for category c.
+func (n *Counts) Add(c Category, delta int) {
+	switch c {
+	case Code:
+		n.Code += delta
+	case String:
+		n.String += delta
+	case Comment:
+		n.Comment += delta
+	}
+}
+
+// Total returns the number of violations in all categories.
+func (n *Counts) Total() int {
+	return n.Code + n.String + n.Comment
+}
+
+// Violation records the position and category of one unmatched matcher.
+type Violation struct {
+	Offset   int64    `json:"offset"` // byte offset from the start of the file
+	Line     int      `json:"line"`   // line number starting from 1
+	Column   int      `json:"column"` // byte column starting from 1
+	Matcher  string   `json:"matcher"`
+	Category Category `json:"category"`
+}
+
+// FileReport summarizes the violations in one source file.
+type FileReport struct {
+	Path       string      `json:"path"`
+	Language   string      `json:"language"`
+	Size       int64       `json:"size"` // file size in bytes
+	Counts     Counts      `json:"counts"`
+	Violations []Violation `json:"violations,omitempty"`
+}
+
+// Summary aggregates the reports for a set of source files.
+type Summary struct {
+	Files    int    `json:"files"`    // number of files analyzed
+	Affected int    `json:"affected"` // number with at least one violation
+	Size     int64  `json:"size"`     // total size in bytes
+	Counts   Counts `json:"counts"`
+}
+
+// add accumulates file report fr into summary s.
+func (s *Summary) add(fr *FileReport) {
+	s.Files++
+	if fr.Counts.Total() > 0 {
+		s.Affected++
+	}
+	s.Size += fr.Size
+	for _, c := range Categories {
+		s.Counts.Add(c, fr.Counts.Get(c))
+	}
+}
+
+// Report summarizes the violations in a tree of source files.
+type Report struct {
+	// Files holds the reports for the files containing violations,
+	// in the order the Analyzer visited them.
+	Files []*FileReport `json:"files"`
+
+	// Languages summarizes all analyzed files by language name.
+	Languages map[string]*Summary `json:"languages"`
+
+	// Total summarizes all analyzed files.
+	Total Summary `json:"total"`
+}
+
+// Add accumulates file report fr into report r.
+// Only reports of files containing violations are retained in r.Files,
+// but all contribute to the summaries.
+func (r *Report) Add(fr *FileReport) {
+	if fr.Counts.Total() > 0 {
+		r.Files = append(r.Files, fr)
+	}
+	if r.Languages == nil {
+		r.Languages = make(map[string]*Summary)
+	}
+	s := r.Languages[fr.Language]
+	if s == nil {
+		s = new(Summary)
+		r.Languages[fr.Language] = s
+	}
+	s.add(fr)
+	r.Total.add(fr)
+}
+
+func (a *Analyzer) config() *matchertext.Config {
+	if a.Config == nil {
+		return matchertext.Standard
+	}
+	return a.Config
+}
+
+func (a *Analyzer) languages() []*Language {
+	if a.Languages == nil {
+		return Languages
+	}
+	return a.Languages
+}
+
+// Language returns the language the Analyzer uses for the file at path,
+// based on its extension, or nil if the Analyzer skips such files.
+func (a *Analyzer) Language(path string) *Language {
+	return languageFor(a.languages(), path)
+}
+
+// Source analyzes the contents src of a source file in language lang,
+// returning a FileReport for it labeled with the given path.
+func (a *Analyzer) Source(path string, lang *Language, src []byte) *FileReport {
+	fr := &FileReport{Path: path, Language: lang.Name, Size: int64(len(src))}
+
+	// Reading from memory cannot fail
+	offs, _ := a.config().UnmatchedOffsets(bytes.NewReader(src))
+	if len(offs) == 0 {
+		return fr
+	}
+	offs.Sort()
+
+	var lines *matchertext.LineIndex
+	if a.Details {
+		lines = matchertext.NewLineIndex(src)
+	}
+	regions := lang.Lex(src)
+	next := 0
+	for _, ofs := range offs {
+		cat := categoryAt(regions, &next, int(ofs))
+		fr.Counts.Add(cat, 1)
+		if a.Details {
+			line, col := lines.Position(int(ofs), matchertext.ByteColumns)
+			fr.Violations = append(fr.Violations, Violation{
+				ofs, line, col, string(src[ofs]), cat})
+		}
+	}
+	return fr
+}
+
+// Walk analyzes the source files in the file tree rooted at root,
+// which may also name a single file.
+// See WalkFS for details.
+func (a *Analyzer) Walk(root string) (*Report, error) {
+	return a.walk(root, filepath.WalkDir, os.ReadFile)
+}
+
+// WalkFS analyzes the source files in the file tree rooted at root
+// within file system fsys, and returns a Report of the results.
+// It analyzes each regular file whose extension identifies one
+// of the Analyzer's languages, and skips all other files.
+// It also skips directories whose names start with a dot,
+// such as version-control metadata,
+// and directories named vendor or node_modules,
+// which typically hold third-party code.
+//
+// WalkFS stops and returns the first error it encounters
+// walking the tree or reading a file.
+func (a *Analyzer) WalkFS(fsys fs.FS, root string) (*Report, error) {
+	walk := func(root string, fn fs.WalkDirFunc) error {
+		return fs.WalkDir(fsys, root, fn)
+	}
+	read := func(name string) ([]byte, error) {
+		return fs.ReadFile(fsys, name)
+	}
+	return a.walk(root, walk, read)
+}
+
+func (a *Analyzer) walk(root string,
+	walk func(string, fs.WalkDirFunc) error,
+	read func(string) ([]byte, error)) (*Report, error) {
+
+	r := new(Report)
+	err := walk(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != root && skipDir(d.Name()) {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		lang := a.Language(path)
+		if lang == nil || !d.Type().IsRegular() {
+			return nil
+		}
+		src, err := read(path)
+		if err != nil {
+			return fmt.Errorf("analyzing %v: %w", path, err)
+		}
+		r.Add(a.Source(path, lang, src))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Return true if the Analyzer should skip a directory with this name.
+func skipDir(name string) bool {
+	return len(name) > 1 && name[0] == '.' ||
+		name == "vendor" || name == "node_modules"
+}
+
+// Walk analyzes the source files in the file tree rooted at root
+// with the default Analyzer options.
+func Walk(root string) (*Report, error) {
+	return new(Analyzer).Walk(root)
+}
@@ -0,0 +1,139 @@
+package analyze
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/dedis/matchertext/go/matchertext"
+)
+
+func TestSource(t *testing.T) {
+	src := "func f() {\n\ts := \"(\" // [\n}\n}"
+	a := Analyzer{Details: true}
+	fr := a.Source("f.go", Go, []byte(src))
+	if fr.Path != "f.go" || fr.Language != "Go" || fr.Size != int64(len(src)) {
+		t.Errorf("wrong file info %v %v %v", fr.Path, fr.Language, fr.Size)
+	}
+	expect := []Violation{
+		{18, 2, 8, "(", String},
+		{24, 2, 14, "[", Comment},
+		{28, 4, 1, "}", Code},
+	}
+	if !reflect.DeepEqual(fr.Violations, expect) {
+		t.Errorf("violations %v, expected %v", fr.Violations, expect)
+	}
+	if fr.Counts != (Counts{Code: 1, String: 1, Comment: 1}) {
+		t.Errorf("counts %+v", fr.Counts)
+	}
+
+	// Without Details, we get only the counts
+	fr = new(Analyzer).Source("f.go", Go, []byte(src))
+	if fr.Violations != nil || fr.Counts.Total() != 3 {
+		t.Errorf("violations %v, counts %+v", fr.Violations, fr.Counts)
+	}
+
+	// With a custom configuration
+	a.Config = matchertext.NewConfig("()")
+	fr = a.Source("f.go", Go, []byte(src))
+	if len(fr.Violations) != 1 || fr.Violations[0].Matcher != "(" {
+		t.Errorf("with parentheses only: violations %v", fr.Violations)
+	}
+}
+
+var testFS = fstest.MapFS{
+	"main.go":               {Data: []byte("// f(x]\nfunc main() {}\n")},
+	"lib/util.c":            {Data: []byte("char *s = \"[\";\n")},
+	"lib/ok.c":              {Data: []byte("int f(int x) { return x; }\n")},
+	"web/app.js":            {Data: []byte("y(\"[\"); /* ) */\n")},
+	"web/README.md":         {Data: []byte("(((")},
+	"tool.py":               {Data: []byte("print('{')\n")},
+	".git/x.go":             {Data: []byte("(((")},
+	"vendor/x/y.go":         {Data: []byte("(((")},
+	"web/node_modules/z.js": {Data: []byte("(((")},
+}
+
+func TestWalkFS(t *testing.T) {
+	r, err := new(Analyzer).WalkFS(testFS, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, fr := range r.Files {
+		paths = append(paths, fr.Path)
+	}
+	expect := []string{"lib/util.c", "main.go", "tool.py", "web/app.js"}
+	if !reflect.DeepEqual(paths, expect) {
+		t.Errorf("affected files %v, expected %v", paths, expect)
+	}
+
+	if s := r.Languages["C"]; s == nil || s.Files != 2 || s.Affected != 1 ||
+		s.Counts != (Counts{String: 1}) {
+		t.Errorf("C summary %+v", s)
+	}
+	if s := r.Languages["JavaScript"]; s == nil ||
+		s.Counts != (Counts{Comment: 1, String: 1}) {
+		t.Errorf("JavaScript summary %+v", s)
+	}
+	total := r.Total
+	if total.Files != 5 || total.Affected != 4 ||
+		total.Counts != (Counts{Code: 0, String: 3, Comment: 3}) {
+		t.Errorf("total summary %+v", total)
+	}
+
+	// Walking a single file
+	r, err = new(Analyzer).WalkFS(testFS, "tool.py")
+	if err != nil || r.Total.Files != 1 || r.Total.Counts.String != 1 {
+		t.Errorf("walking one file: %+v, %v", r, err)
+	}
+
+	// Walking a nonexistent path
+	if _, err := new(Analyzer).WalkFS(testFS, "nonexistent"); err == nil {
+		t.Errorf("walking nonexistent path succeeded")
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	r, err := (&Analyzer{Details: true}).WalkFS(testFS, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := new(bytes.Buffer)
+	if err := r.WriteJSON(buf); err != nil {
+		t.Fatal(err)
+	}
+	var d Report
+	if err := json.Unmarshal(buf.Bytes(), &d); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&d, r) {
+		t.Errorf("JSON round trip produced %+v, expected %+v", d, r)
+	}
+}
+
+func TestWriteText(t *testing.T) {
+	r, err := (&Analyzer{Details: true}).WalkFS(testFS, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := new(bytes.Buffer)
+	if err := r.WriteText(buf); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(buf.String(), "\n")
+	for i, expect := range []string{
+		"FILE        LANGUAGE    CODE  STRING  COMMENT  TOTAL",
+		"lib/util.c  C           0     1       0        1",
+		"  1:12      [           string",
+	} {
+		if i >= len(lines) || strings.TrimRight(lines[i], " ") != expect {
+			t.Errorf("line %v: expected %q in\n%v", i, expect, buf)
+		}
+	}
+	if !strings.Contains(buf.String(), "\nall ") {
+		t.Errorf("no total summary in\n%v", buf)
+	}
+}
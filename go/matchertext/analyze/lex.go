@@ -0,0 +1,238 @@
+package analyze
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Category classifies where in a source file a byte appears.
+type Category int
+
+const (
+	Code    Category = iota // Anywhere outside a string literal or comment
+	String                  // Within a string or character literal
+	Comment                 // Within a comment
+)
+
+// Categories lists all categories in the order reports present them.
+var Categories = []Category{Code, String, Comment}
+
+var categoryNames = [...]string{"code", "string", "comment"}
+
+// String returns the lower-case name of category c.
+func (c Category) String() string {
+	if c < 0 || int(c) >= len(categoryNames) {
+		return "unknown"
+	}
+	return categoryNames[c]
+}
+
+// MarshalText encodes category c as its name, for JSON reports.
+func (c Category) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// UnmarshalText decodes a category name that MarshalText produced.
+func (c *Category) UnmarshalText(text []byte) error {
+	i := slices.Index(categoryNames[:], string(text))
+	if i < 0 {
+		return fmt.Errorf("analyze: unknown category %q", text)
+	}
+	*c = Category(i)
+	return nil
+}
+
+// Region is a span of a source file that a lexer places in a Category.
+// Start and End are byte offsets, with End exclusive.
+type Region struct {
+	Start, End int
+	Category   Category
+}
+
+// Delims describes a construct delimited by Open and Close strings,
+// such as a block comment or a string literal.
+type Delims struct {
+	Open, Close string
+
+	// Escape, if nonzero, is a byte that causes the lexer
+	// to skip the byte following it, such as the close delimiter.
+	Escape byte
+
+	// Multiline indicates that the construct may span lines.
+	// A construct that may not is terminated at the end of its line
+	// even if its Close delimiter is missing.
+	Multiline bool
+}
+
+// Syntax describes the lexical structure of a programming language
+// just far enough to find its string literals and comments.
+//
+// Syntax is deliberately lightweight:
+// it ignores constructs such as JavaScript regular expression literals,
+// template-string substitutions, and preprocessor directives,
+// treating them as code or as part of the enclosing literal.
+// This is good enough for estimating where violations occur
+// in typical code, but not for parsing.
+type Syntax struct {
+	LineComments  []string // Prefixes starting comments that end at newline
+	BlockComments []Delims // Delimited comments
+	Strings       []Delims // String and character literals
+}
+
+// Lex finds the string literals and comments in src,
+// returning them as Regions in order of increasing offset.
+// All bytes outside the returned regions are in the Code category.
+//
+// At any position, Lex tries block comments first, then line comments,
+// then string literals, each in the order the Syntax lists them.
+// A Syntax should thus list longer delimiters before their prefixes,
+// such as Python's """ before ".
+func (s *Syntax) Lex(src []byte) []Region {
+	var rs []Region
+	for i := 0; i < len(src); {
+		r, ok := s.token(src, i)
+		if !ok {
+			i++
+			continue
+		}
+		rs = append(rs, r)
+		i = r.End
+	}
+	return rs
+}
+
+// Return the comment or string literal starting at offset i of src, if any.
+func (s *Syntax) token(src []byte, i int) (Region, bool) {
+	rest := src[i:]
+	for _, d := range s.BlockComments {
+		if bytes.HasPrefix(rest, []byte(d.Open)) {
+			return Region{i, d.end(src, i+len(d.Open)), Comment}, true
+		}
+	}
+	for _, p := range s.LineComments {
+		if bytes.HasPrefix(rest, []byte(p)) {
+			end := bytes.IndexByte(rest, '\n')
+			if end < 0 {
+				end = len(rest)
+			}
+			return Region{i, i + end, Comment}, true
+		}
+	}
+	for _, d := range s.Strings {
+		if bytes.HasPrefix(rest, []byte(d.Open)) {
+			return Region{i, d.end(src, i+len(d.Open)), String}, true
+		}
+	}
+	return Region{}, false
+}
+
+// Return the offset just past the construct d whose body starts at offset j,
+// or the end of the line or of src if the construct is unterminated.
+func (d *Delims) end(src []byte, j int) int {
+	for j < len(src) {
+		switch b := src[j]; {
+		case d.Escape != 0 && b == d.Escape:
+			j += 2
+			continue
+		case bytes.HasPrefix(src[j:], []byte(d.Close)):
+			return j + len(d.Close)
+		case b == '\n' && !d.Multiline:
+			return j
+		}
+		j++
+	}
+	return len(src)
+}
+
+// Language associates a Syntax with the file extensions that use it.
+type Language struct {
+	Name       string   // Human-readable name of the language
+	Extensions []string // File name extensions, including the leading dot
+	Syntax
+}
+
+// Lightweight descriptions of some common languages.
+var (
+	Go = &Language{
+		Name:       "Go",
+		Extensions: []string{".go"},
+		Syntax: Syntax{
+			LineComments:  []string{"//"},
+			BlockComments: []Delims{{Open: "/*", Close: "*/", Multiline: true}},
+			Strings: []Delims{
+				{Open: `"`, Close: `"`, Escape: '\\'},
+				{Open: `'`, Close: `'`, Escape: '\\'},
+				{Open: "`", Close: "`", Multiline: true},
+			},
+		},
+	}
+	C = &Language{
+		Name:       "C",
+		Extensions: []string{".c", ".h", ".cc", ".cpp", ".cxx", ".hh", ".hpp"},
+		Syntax: Syntax{
+			LineComments:  []string{"//"},
+			BlockComments: []Delims{{Open: "/*", Close: "*/", Multiline: true}},
+			Strings: []Delims{
+				{Open: `"`, Close: `"`, Escape: '\\'},
+				{Open: `'`, Close: `'`, Escape: '\\'},
+			},
+		},
+	}
+	JavaScript = &Language{
+		Name:       "JavaScript",
+		Extensions: []string{".js", ".mjs", ".cjs", ".jsx", ".ts", ".tsx"},
+		Syntax: Syntax{
+			LineComments:  []string{"//"},
+			BlockComments: []Delims{{Open: "/*", Close: "*/", Multiline: true}},
+			Strings: []Delims{
+				{Open: `"`, Close: `"`, Escape: '\\'},
+				{Open: `'`, Close: `'`, Escape: '\\'},
+				{Open: "`", Close: "`", Escape: '\\', Multiline: true},
+			},
+		},
+	}
+	Python = &Language{
+		Name:       "Python",
+		Extensions: []string{".py", ".pyw"},
+		Syntax: Syntax{
+			LineComments: []string{"#"},
+			Strings: []Delims{
+				{Open: `"""`, Close: `"""`, Escape: '\\', Multiline: true},
+				{Open: `'''`, Close: `'''`, Escape: '\\', Multiline: true},
+				{Open: `"`, Close: `"`, Escape: '\\'},
+				{Open: `'`, Close: `'`, Escape: '\\'},
+			},
+		},
+	}
+)
+
+// Languages lists the languages an Analyzer recognizes by default.
+var Languages = []*Language{Go, C, JavaScript, Python}
+
+// languageFor returns the language among langs
+// whose extensions include that of the file at path, or nil if none does.
+func languageFor(langs []*Language, path string) *Language {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, l := range langs {
+		if slices.Contains(l.Extensions, ext) {
+			return l
+		}
+	}
+	return nil
+}
+
+// Return the category of the byte at offset ofs,
+// given regions sorted by offset, starting the search at regions[*next].
+// Successive calls must pass increasing offsets.
+func categoryAt(regions []Region, next *int, ofs int) Category {
+	for *next < len(regions) && regions[*next].End <= ofs {
+		*next++
+	}
+	if *next < len(regions) && regions[*next].Start <= ofs {
+		return regions[*next].Category
+	}
+	return Code
+}
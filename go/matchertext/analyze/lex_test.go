@@ -0,0 +1,84 @@
+package analyze
+
+import (
+	"testing"
+)
+
+// Render the categories Lex assigns to each byte of src
+// as a string of '.' for code, 's' for strings, and '#' for comments.
+func lexMask(lang *Language, src string) string {
+	mask := []byte(src)
+	for i := range mask {
+		mask[i] = '.'
+	}
+	for _, r := range lang.Lex([]byte(src)) {
+		for i := r.Start; i < r.End; i++ {
+			mask[i] = ".s#"[r.Category]
+		}
+	}
+	return string(mask)
+}
+
+func TestLex(t *testing.T) {
+	for i, tc := range []struct {
+		lang      *Language
+		src, mask string
+	}{
+		{Go, `f(x)`, `....`},
+		{Go, `a("(") // )`, `..sss..####`},
+		{Go, `"a\"(" + '('`, `ssssss...sss`},
+		{Go, "`a\\`+b", "ssss.."},
+		{Go, "/* ( \n */x", "#########."},
+		{Go, "\"unterminated\nx", "sssssssssssss.."},
+		{Go, "`raw\n(`x", "sssssss."},
+		{C, `'\''+"/*"`, `ssss.ssss`},
+		{C, "/* unterminated (", "#################"},
+		{JavaScript, "`t\n${x}`;", "ssssssss."},
+		{JavaScript, `x // "(`, `..#####`},
+		{Python, `x = '(' # [`, `....sss.###`},
+		{Python, "\"\"\"a\n\"(\"\"\"b", "ssssssssss."},
+		{Python, `'''a'''+"#"`, `sssssss.sss`},
+		{Python, `r"\"" #`, `.ssss.#`},
+	} {
+		if mask := lexMask(tc.lang, tc.src); mask != tc.mask {
+			t.Errorf("%v: %v %q lexed as %q, expected %q",
+				i, tc.lang.Name, tc.src, mask, tc.mask)
+		}
+	}
+}
+
+func TestCategoryText(t *testing.T) {
+	for _, c := range Categories {
+		text, err := c.MarshalText()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var d Category
+		if err := d.UnmarshalText(text); err != nil || d != c {
+			t.Errorf("%v: decoded %q as %v, %v", c, text, d, err)
+		}
+	}
+	var d Category
+	if err := d.UnmarshalText([]byte("bogus")); err == nil {
+		t.Errorf("decoded bogus category as %v", d)
+	}
+}
+
+func TestLanguageFor(t *testing.T) {
+	for i, tc := range []struct {
+		path string
+		lang *Language
+	}{
+		{"a/b.go", Go},
+		{"x.H", C},
+		{"lib.cpp", C},
+		{"app.tsx", JavaScript},
+		{"setup.py", Python},
+		{"README.md", nil},
+		{"Makefile", nil},
+	} {
+		if lang := languageFor(Languages, tc.path); lang != tc.lang {
+			t.Errorf("%v: language for %v is %v", i, tc.path, lang)
+		}
+	}
+}
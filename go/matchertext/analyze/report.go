@@ -0,0 +1,67 @@
+package analyze
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"text/tabwriter"
+)
+
+// WriteJSON writes report r to w as indented JSON.
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteText writes report r to w as human-readable text:
+// a table of the files containing violations,
+// each followed by the positions of its violations if recorded,
+// then a table summarizing the files of each language.
+func (r *Report) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	row := func(cells ...any) {
+		strs := make([]string, len(cells))
+		for i, c := range cells {
+			strs[i] = fmt.Sprint(c)
+		}
+		fmt.Fprintln(tw, strings.Join(strs, "\t"))
+	}
+	header := func(first ...any) {
+		for _, c := range Categories {
+			first = append(first, strings.ToUpper(c.String()))
+		}
+		row(append(first, "TOTAL")...)
+	}
+	counts := func(n *Counts, first ...any) {
+		for _, c := range Categories {
+			first = append(first, n.Get(c))
+		}
+		row(append(first, n.Total())...)
+	}
+
+	if len(r.Files) > 0 {
+		header("FILE", "LANGUAGE")
+		for _, fr := range r.Files {
+			counts(&fr.Counts, fr.Path, fr.Language)
+			for _, v := range fr.Violations {
+				row(fmt.Sprintf("  %v:%v", v.Line, v.Column),
+					v.Matcher, v.Category)
+			}
+		}
+		row()
+	}
+
+	header("LANGUAGE", "FILES", "AFFECTED", "BYTES")
+	summary := func(name string, s *Summary) {
+		counts(&s.Counts, name, s.Files, s.Affected, s.Size)
+	}
+	for _, name := range slices.Sorted(maps.Keys(r.Languages)) {
+		summary(name, r.Languages[name])
+	}
+	summary("all", &r.Total)
+	return tw.Flush()
+}
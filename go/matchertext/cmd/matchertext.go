@@ -0,0 +1,122 @@
+// Package matchertext is a command-line tool for working with matchertext.
+//
+// Usage:
+//
+//	matchertext [COMMAND] <path> [OPTIONS]
+//
+// Commands:
+//   - analyze: Report matchertext violations in legacy source files
+//
+// Examples:
+//
+//	matchertext analyze ~/src/project
+//	matchertext analyze main.go --details
+//	matchertext analyze ~/src/project --json > report.json
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/dedis/matchertext/go/matchertext"
+	"github.com/dedis/matchertext/go/matchertext/analyze"
+)
+
+const usage = `Matchertext Command-Line Tool
+
+USAGE:
+    %s [COMMAND] <path> [OPTIONS]
+
+ARGS:
+    <path>                                Source file or directory
+
+COMMANDS:
+    help                                  Print this help message
+    analyze <file|directory> [OPTIONS]    Report matchertext violations in source files
+
+OPTIONS (analyze):
+    --json                                Write the report as JSON instead of text
+    --details                             Report the position of every violation
+    --pairs <pairs>                       Matcher pairs to check (default: "()[]{}")
+
+DESCRIPTION:
+    The analyze command finds unmatched matchers in Go, C, JavaScript,
+    and Python source files, and reports how many appear in string
+    literals, in comments, and elsewhere in the code.
+
+EXAMPLES:
+    %[1]s analyze ~/src/project
+    %[1]s analyze main.go --details
+    %[1]s analyze ~/src/project --json > report.json
+`
+
+const CmdAnalyze = "analyze"
+
+func main() {
+	args := os.Args
+
+	if len(args) < 2 {
+		printUsage(args[0])
+		os.Exit(1)
+	}
+
+	switch command := args[1]; command {
+	case "help":
+		printUsage(args[0])
+	case CmdAnalyze:
+		if len(args) < 3 {
+			log.Fatalf("'%s' requires an input path", command)
+		}
+		if err := Analyze(os.Stdout, args[2], args[3:]); err != nil {
+			log.Fatal(err)
+		}
+	default:
+		log.Fatalf("Unknown command: %s", command)
+	}
+}
+
+// Analyze walks the source tree at path and writes a report to w,
+// according to the options in rest.
+// It returns an error for invalid options without walking the tree.
+func Analyze(w io.Writer, path string, rest []string) error {
+	var a analyze.Analyzer
+	asJSON := false
+	for i := 0; i < len(rest); i++ {
+		switch rest[i] {
+		case "--json":
+			asJSON = true
+		case "--details":
+			a.Details = true
+		case "--pairs":
+			i++
+			if i >= len(rest) {
+				return errors.New("--pairs requires a value")
+			}
+			c, err := matchertext.ParseConfig(rest[i])
+			if err != nil {
+				return fmt.Errorf("invalid --pairs value: %w", err)
+			}
+			a.Config = c
+		default:
+			return fmt.Errorf("unknown option for '%s': %s",
+				CmdAnalyze, rest[i])
+		}
+	}
+
+	r, err := a.Walk(path)
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		return r.WriteJSON(w)
+	}
+	return r.WriteText(w)
+}
+
+// printUsage prints the help message to stderr.
+func printUsage(program string) {
+	fmt.Fprintf(os.Stderr, usage, program)
+}
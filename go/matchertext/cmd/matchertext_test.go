@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAnalyzeOptions(t *testing.T) {
+	for i, rest := range [][]string{
+		{"--pairs"},
+		{"--pairs", "("},
+		{"--pairs", "(("},
+		{"--bogus"},
+	} {
+		var sb strings.Builder
+		if err := Analyze(&sb, ".", rest); err == nil {
+			t.Errorf("%v %q: accepted invalid options", i, rest)
+		}
+		if sb.Len() != 0 {
+			t.Errorf("%v %q: wrote output %q", i, rest, sb.String())
+		}
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	dir := t.TempDir()
+	src := "package a\n\n// half-open [0,1)\nvar s = \"(\"\n"
+	if err := os.WriteFile(filepath.Join(dir, "a.go"), []byte(src),
+		0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var sb strings.Builder
+	if err := Analyze(&sb, dir, []string{"--json"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(sb.String(), "a.go") {
+		t.Errorf("report does not mention a.go: %s", sb.String())
+	}
+
+	// With only braces sensitive, the file is valid
+	sb.Reset()
+	if err := Analyze(&sb, dir, []string{"--json", "--pairs", "{}"}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(sb.String(), "a.go") {
+		t.Errorf("report mentions a.go: %s", sb.String())
+	}
+}
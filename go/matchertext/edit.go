@@ -0,0 +1,151 @@
+package matchertext
+
+import (
+	"sort"
+)
+
+// Source returns the source the index currently describes.
+// The returned slice must not be modified,
+// and remains valid only until the next Edit.
+// After an Edit, Source takes time proportional to the length
+// of the source following the edit.
+func (x *Index) Source() []byte {
+	x.moveGap(x.root.size - 1)
+	return x.buf[:x.gap:x.gap]
+}
+
+// Edit updates the index to reflect an edit to its source
+// that replaces the deleted bytes starting at offset ofs
+// with the bytes in inserted.
+//
+// The first Edit copies the source into a buffer of the index's own,
+// so it never modifies the source passed to NewIndex.
+// That buffer keeps a gap at the most recent edit,
+// so that an edit near the last one moves few bytes.
+//
+// Rather than re-indexing the whole source,
+// Edit re-scans only the content of the innermost matched pair
+// that encloses the edit and still matches after it,
+// moving outward only as far as the edit disturbs the pairs around it.
+// The offsets of the pairs and unmatched matchers beyond the edit
+// are relative to the closers of the pairs enclosing them,
+// so Edit need not adjust them.
+// The resulting index is identical to one built afresh by NewIndex.
+//
+// Edit panics if the deleted bytes are not within the source.
+func (x *Index) Edit(ofs, deleted int, inserted []byte) {
+	if ofs < 0 || deleted < 0 || ofs+deleted > x.root.size-1 {
+		panic("matchertext: edit out of range")
+	}
+	delta := len(inserted) - deleted
+
+	// Find the pairs whose openers precede the edit
+	// and whose closers follow it, from the outside in,
+	// splitting each at the next so that the offsets beyond the edit
+	// are relative to its closer.
+	path := []*pair{&x.root}
+	opens := []int{x.root.open}
+	for {
+		p, o := path[len(path)-1], opens[len(opens)-1]
+		k := p.kidAt(o, ofs-1)
+		if k < 0 {
+			break
+		}
+		ko := p.kidOpen(o, k)
+		if ko+p.kids[k].size < ofs+deleted {
+			break
+		}
+		p.split(o, k+1, ko)
+		path = append(path, &p.kids[k])
+		opens = append(opens, ko)
+	}
+
+	// Edit the source at the gap
+	if !x.own || x.end-x.gap+deleted < len(inserted) {
+		x.grow(len(inserted))
+	}
+	x.moveGap(ofs)
+	x.end += deleted
+	x.gap += copy(x.buf[x.gap:x.end], inserted)
+
+	// Re-scan the innermost pair's content, moving outward as long as
+	// the edited content no longer leaves the pair matched.
+	// The virtual pair enclosing the whole source always matches.
+	for i := len(path) - 1; i >= 0; i-- {
+		p, o := path[i], opens[i]
+		close := o + p.size + delta
+		x.moveGap(close)
+		closer := byte(0)
+		if i > 0 {
+			closer = x.buf[x.end]
+		}
+		ps, um, closes := indexPairs(x.cfg, x.buf[o+1:close], o+1, closer)
+		if !closes && i > 0 {
+			continue
+		}
+
+		x.n += len(ps) - p.count()
+		p.build(ps, um, o)
+		for _, a := range path[:i+1] {
+			a.size += delta
+		}
+		return
+	}
+}
+
+// Move p's splits so that its first k children
+// and its unmatched matchers before offset ofs
+// are relative to its opener, at offset o, and the rest to its closer.
+func (p *pair) split(o, k, ofs int) {
+	for ; p.ksplit < k; p.ksplit++ {
+		p.kids[p.ksplit].open += p.size
+	}
+	for ; p.ksplit > k; p.ksplit-- {
+		p.kids[p.ksplit-1].open -= p.size
+	}
+	j := sort.Search(len(p.um), func(j int) bool {
+		return p.umOffset(o, j) >= ofs
+	})
+	for ; p.usplit < j; p.usplit++ {
+		p.um[p.usplit] += p.size
+	}
+	for ; p.usplit > j; p.usplit-- {
+		p.um[p.usplit-1] -= p.size
+	}
+}
+
+// Return the number of pairs nested within p at any depth.
+func (p *pair) count() int {
+	n := 0
+	stack := []*pair{p}
+	for len(stack) > 0 {
+		q := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		n += len(q.kids)
+		for k := range q.kids {
+			stack = append(stack, &q.kids[k])
+		}
+	}
+	return n
+}
+
+// Move the gap in the source buffer to offset ofs in the source.
+func (x *Index) moveGap(ofs int) {
+	if ofs < x.gap {
+		x.end -= copy(x.buf[x.end-(x.gap-ofs):], x.buf[ofs:x.gap])
+	} else {
+		x.end += copy(x.buf[x.gap:], x.buf[x.end:x.end+ofs-x.gap])
+	}
+	x.gap = ofs
+}
+
+// Copy the source into a new buffer of the index's own,
+// leaving a gap of at least need bytes at the same offset.
+func (x *Index) grow(need int) {
+	n := x.root.size - 1
+	gap := max(need, n/16, 64)
+	buf := make([]byte, n+gap)
+	copy(buf, x.buf[:x.gap])
+	copy(buf[x.gap+gap:], x.buf[x.end:])
+	x.buf, x.end, x.own = buf, x.gap+gap, true
+}
@@ -0,0 +1,108 @@
+package matchertext
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+type editTest struct {
+	s, ins   string
+	ofs, del int
+}
+
+var editTests = []editTest{
+	{"a(b)c", "x", 2, 1},      // replace within a pair
+	{"a(b)c", "(", 2, 0},      // unmatched opener within a pair
+	{"a(b)c", ")", 2, 0},      // closer ends the pair early
+	{"a(b)c", "", 1, 1},       // delete an opener
+	{"a(b)c", "", 3, 1},       // delete a closer
+	{"[(b)]", "(", 2, 0},      // opener steals an outer closer
+	{"[(b)]", "]", 3, 0},      // closer ends an outer pair
+	{"{[(x)]}", "}", 4, 0},    // mismatched closer escapes all pairs
+	{"([)]", "", 1, 1},        // deletion repairs an unmatched pair
+	{"a)b(c", "(", 1, 0},      // edits at the outermost level
+	{"(a)(b)", "[", 5, 0},     // only the second pair is disturbed
+	{"([{}])", "xyz", 3, 0},   // insertion in the innermost pair
+	{"([{}])", "", 0, 6},      // delete everything
+	{"", "([{", 0, 0},         // insert into an empty source
+	{"((((x))))", "))", 5, 0}, // unbalance deep nesting
+}
+
+// Check that editing an index matches building a fresh index of the result.
+func checkEdit(t *testing.T, c *Config, src []byte, ofs, del int, ins []byte) {
+	t.Helper()
+	x := c.NewIndex(src)
+	x.Edit(ofs, del, ins)
+
+	want := append(append(append([]byte{}, src[:ofs]...), ins...),
+		src[ofs+del:]...)
+	if !bytes.Equal(x.Source(), want) {
+		t.Fatalf("%q edit(%v,%v,%q): source %q", src, ofs, del, ins,
+			x.Source())
+	}
+	checkIndex(t, c, want, x)
+
+	os, _ := c.UnmatchedOffsets(bytes.NewReader(want))
+	os.Sort()
+	if !eqOffsetSlice(x.Unmatched(), os) {
+		t.Fatalf("%q edit(%v,%v,%q): expected %v got %v",
+			src, ofs, del, ins, os, x.Unmatched())
+	}
+}
+
+func TestIndexEdit(t *testing.T) {
+	for _, et := range editTests {
+		checkEdit(t, Standard, []byte(et.s), et.ofs, et.del, []byte(et.ins))
+	}
+
+	// Edits that insert whole unmatched test cases anywhere
+	for _, ut := range unmatchedTests {
+		for _, et := range editTests {
+			for ofs := 0; ofs <= len(et.s); ofs++ {
+				checkEdit(t, Standard, []byte(et.s), ofs, 0,
+					[]byte(ut.s))
+			}
+		}
+	}
+}
+
+func TestIndexEditRandom(t *testing.T) {
+	rng := rand.New(rand.NewSource(5))
+	for _, c := range []*Config{Standard, Braces} {
+		for i := 0; i < 2000; i++ {
+			src := []byte(randString(rng, "ab()[]{}", rng.Intn(40)))
+			ofs := rng.Intn(len(src) + 1)
+			del := rng.Intn(len(src) - ofs + 1)
+			ins := []byte(randString(rng, "ab()[]{}", rng.Intn(4)))
+			checkEdit(t, c, src, ofs, del, ins)
+		}
+	}
+
+	// Repeated edits to one index must remain consistent
+	x := NewIndex(nil)
+	for i := 0; i < 2000; i++ {
+		n := len(x.Source())
+		ofs := rng.Intn(n + 1)
+		del := rng.Intn(min(n-ofs, 3) + 1)
+		x.Edit(ofs, del, []byte(randString(rng, "ab()[]{}", rng.Intn(4))))
+		checkIndex(t, Standard, x.Source(), x)
+	}
+}
+
+func BenchmarkIndexEdit(b *testing.B) {
+	doc := append(append([]byte("["), benchDocs[0].doc...), ']')
+	x := NewIndex(doc)
+	ofs := bytes.IndexByte(doc[len(doc)/2:], '(') + len(doc)/2 + 1
+	for b.Loop() {
+		x.Edit(ofs, 1, []byte{'x'})
+	}
+}
+
+func BenchmarkNewIndex(b *testing.B) {
+	doc := append(append([]byte("["), benchDocs[0].doc...), ']')
+	b.SetBytes(int64(len(doc)))
+	for b.Loop() {
+		NewIndex(doc)
+	}
+}
@@ -0,0 +1,78 @@
+package matchertext
+
+import (
+	"fmt"
+)
+
+// ErrorKind is a machine-readable classification of a SyntaxError.
+// Each ErrorKind is also an error value usable with errors.Is:
+// errors.Is(err, UnmatchedOpener), for example,
+// reports whether err is a SyntaxError of kind UnmatchedOpener.
+//
+// Languages built on matchertext may define their own kinds
+// for their own syntax errors, and report them via Parser.SyntaxErrorKind.
+type ErrorKind string
+
+const (
+	UnmatchedOpener  ErrorKind = "unmatched opener"  // Opener never closed
+	UnmatchedCloser  ErrorKind = "unmatched closer"  // Closer never opened
+	MismatchedCloser ErrorKind = "mismatched closer" // Opener closed wrongly
+	ExpectedOpener   ErrorKind = "expected opener"   // ReadPair found no opener
+
+	DisallowedByte ErrorKind = "disallowed byte"      // Byte outside alphabet
+	DisallowedRune ErrorKind = "disallowed character" // Rune outside alphabet
+	InvalidUTF8    ErrorKind = "invalid UTF-8"        // Ill-formed UTF-8
+)
+
+// Error returns the name of the error kind.
+func (k ErrorKind) Error() string {
+	return string(k)
+}
+
+// SyntaxError describes any syntax error the parser encounters.
+type SyntaxError struct {
+	kind      ErrorKind
+	msg       string
+	ofs, end  int64
+	line, col int
+}
+
+// Error returns a human-readable description of the error.
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%v:%v %v", e.line, e.col, e.msg)
+}
+
+// Is returns true if target is the ErrorKind of e,
+// so that errors.Is(err, kind) identifies syntax errors by kind.
+func (e *SyntaxError) Is(target error) bool {
+	k, ok := target.(ErrorKind)
+	return ok && k != "" && k == e.kind
+}
+
+// Kind returns the machine-readable kind of the error,
+// or the empty string if the error has no specific kind.
+func (e *SyntaxError) Kind() ErrorKind {
+	return e.kind
+}
+
+// Offset returns the byte position at which the error occurred.
+func (e *SyntaxError) Offset() int64 {
+	return e.ofs
+}
+
+// Span returns the byte offsets of the start and end of the source text
+// responsible for the error.
+// The span of a MismatchedCloser error covers both the opener
+// and the mismatched closer, together with everything in between.
+// The span of an error about a single matcher or byte covers just that byte,
+// while that of an error about a UTF-8 sequence covers the sequence.
+// The span of an error at end-of-file is empty.
+func (e *SyntaxError) Span() (start, end int64) {
+	return e.ofs, e.end
+}
+
+// Position returns the line and column number at which the error occurred,
+// with the column counted in the unit the parser's Columns field selected.
+func (e *SyntaxError) Position() (line int, column int) {
+	return e.line, e.col
+}
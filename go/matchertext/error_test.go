@@ -0,0 +1,69 @@
+package matchertext
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSyntaxErrorKinds(t *testing.T) {
+	tests := []struct {
+		in         string
+		policy     Policy
+		kind       ErrorKind
+		start, end int64
+	}{
+		{"ab(cd", 0, UnmatchedOpener, 2, 3},
+		{"ab)cd", 0, UnmatchedCloser, 2, 3},
+		{"a(b]c", 0, MismatchedCloser, 1, 4},
+		{"a(b{c]d", 0, MismatchedCloser, 3, 6},
+		{"a\x00b", RejectNUL, DisallowedByte, 1, 2},
+		{"a\x01b", RejectControl, DisallowedByte, 1, 2},
+		{"a\u0085b", RejectControl, DisallowedRune, 1, 3},
+		{"a\xE2\x82b", RejectInvalidUTF8, InvalidUTF8, 1, 3},
+		{"a\xFFb", RejectInvalidUTF8, InvalidUTF8, 1, 2},
+		{"a\xE2\x82", RejectInvalidUTF8, InvalidUTF8, 1, 3},
+	}
+	for i, et := range tests {
+		p := NewParser(strings.NewReader(et.in))
+		p.Policy = et.policy
+		err := p.ReadAll(&testHandler{p: p})
+		if !errors.Is(err, et.kind) {
+			t.Errorf("%v %q: expected %v got %v", i, et.in, et.kind, err)
+			continue
+		}
+		var se *SyntaxError
+		if !errors.As(err, &se) || se.Kind() != et.kind {
+			t.Errorf("%v %q: wrong kind", i, et.in)
+			continue
+		}
+		if start, end := se.Span(); start != et.start || end != et.end {
+			t.Errorf("%v %q: span %v-%v, expected %v-%v",
+				i, et.in, start, end, et.start, et.end)
+		}
+		if errors.Is(err, ExpectedOpener) {
+			t.Errorf("%v %q: matches wrong kind", i, et.in)
+		}
+	}
+
+	// ReadPair reports a missing opener, with an empty span at end-of-file
+	for i, in := range []string{"x", ""} {
+		p := NewParser(strings.NewReader(in))
+		err := p.ReadPair(&testHandler{p: p}, '(', ')')
+		var se *SyntaxError
+		if !errors.As(err, &se) || se.Kind() != ExpectedOpener {
+			t.Errorf("%v %q: expected %v got %v",
+				i, in, ExpectedOpener, err)
+			continue
+		}
+		if start, end := se.Span(); end-start != int64(len(in)) {
+			t.Errorf("%v %q: span %v-%v", i, in, start, end)
+		}
+	}
+
+	// Client errors without a kind match no kind
+	p := NewParser(strings.NewReader(""))
+	if errors.Is(p.SyntaxError("oops"), ErrorKind("")) {
+		t.Errorf("error without kind matches empty kind")
+	}
+}
@@ -0,0 +1,226 @@
+package matchertext
+
+import (
+	"fmt"
+	"io"
+)
+
+// Escapes is a dictionary of escape sequences
+// for converting arbitrary text into valid matchertext.
+//
+// Entries for the matchers of a configuration
+// replace only the unmatched occurrences of those matchers,
+// leaving matched pairs intact.
+// Entries for the standard ASCII matchers are likewise ignored
+// in configurations where they are not sensitive.
+// Entries for any other bytes, typically the byte introducing
+// the escape sequences themselves, replace every occurrence,
+// so that the escaped text can be decoded unambiguously.
+type Escapes map[byte]string
+
+// MinMLEscapes escapes unmatched matchers as MinML character references
+// such as [(<)] for an open parenthesis and [[>]] for a close bracket.
+var MinMLEscapes = Escapes{
+	'(': "[(<)]", ')': "[(>)]",
+	'[': "[[<]]", ']': "[[>]]",
+	'{': "[{<}]", '}': "[{>}]",
+}
+
+// XMLEscapes escapes unmatched matchers as XML numeric character references
+// such as &#40; for an open parenthesis,
+// and escapes every ampersand as &amp;.
+var XMLEscapes = Escapes{
+	'(': "&#40;", ')': "&#41;",
+	'[': "&#91;", ']': "&#93;",
+	'{': "&#123;", '}': "&#125;",
+	'&': "&amp;",
+}
+
+// PercentEscapes escapes unmatched matchers using URI percent-encoding
+// such as %28 for an open parenthesis,
+// and escapes every percent sign as %25.
+var PercentEscapes = Escapes{
+	'(': "%28", ')': "%29",
+	'[': "%5B", ']': "%5D",
+	'{': "%7B", '}': "%7D",
+	'%': "%25",
+}
+
+// CEscapes escapes unmatched matchers as C-style hexadecimal escapes
+// such as \x28 for an open parenthesis,
+// and escapes every backslash as a double backslash.
+var CEscapes = Escapes{
+	'(': `\x28`, ')': `\x29`,
+	'[': `\x5B`, ']': `\x5D`,
+	'{': `\x7B`, '}': `\x7D`,
+	'\\': `\\`,
+}
+
+// CPairEscapes escapes unmatched matchers using the paired escapes
+// proposed for C-like string literals in the matchertext paper,
+// such as \o() for an open parenthesis and \c[] for a close bracket,
+// and escapes every backslash as a double backslash.
+// Each escape itself contains a matched pair
+// and thus remains valid matchertext.
+var CPairEscapes = Escapes{
+	'(': `\o()`, ')': `\c()`,
+	'[': `\o[]`, ']': `\c[]`,
+	'{': `\o{}`, '}': `\c{}`,
+	'\\': `\\`,
+}
+
+// EscapeWriter is an io.WriteCloser that escapes the unmatched matchers
+// in the text written to it, writing the resulting matchertext
+// to an underlying writer.
+//
+// Since an opener is unmatched only if its closer never appears,
+// an EscapeWriter holds back text following a pending opener
+// until the opener is closed or the EscapeWriter itself is closed.
+// The caller must call Close to flush this text
+// after writing the entire input.
+type EscapeWriter struct {
+	w   io.Writer
+	f   unmatchedFilter
+	out *escapeSink
+}
+
+// NewEscapeWriter creates an EscapeWriter that escapes text using
+// the escape dictionary e and the Standard configuration,
+// and writes the resulting matchertext to w.
+func NewEscapeWriter(w io.Writer, e Escapes) *EscapeWriter {
+	return Standard.NewEscapeWriter(w, e)
+}
+
+// NewEscapeWriter is like the NewEscapeWriter function
+// but escapes the unmatched matchers of configuration c.
+// It panics if e lacks an escape for any matcher of c.
+func (c *Config) NewEscapeWriter(w io.Writer, e Escapes) *EscapeWriter {
+	s := newEscapeSink(c, e)
+	return &EscapeWriter{w: w, f: unmatchedFilter{cfg: c, out: s}, out: s}
+}
+
+// Write escapes the text in p and writes as much of the result
+// as is yet known to the underlying writer.
+func (ew *EscapeWriter) Write(p []byte) (int, error) {
+	if err := ew.f.write(p); err != nil {
+		return 0, err
+	}
+	return len(p), ew.drain()
+}
+
+// Close escapes any openers still pending as unmatched
+// and writes all remaining text to the underlying writer.
+// Close does not close the underlying writer.
+func (ew *EscapeWriter) Close() error {
+	if err := ew.f.close(); err != nil {
+		return err
+	}
+	return ew.drain()
+}
+
+// Write all escaped text produced so far to the underlying writer.
+func (ew *EscapeWriter) drain() error {
+	if len(ew.out.buf) == 0 {
+		return nil
+	}
+	_, err := ew.w.Write(ew.out.buf)
+	ew.out.buf = ew.out.buf[:0]
+	return err
+}
+
+// EscapeReader is an io.Reader that reads text from an underlying reader
+// and yields the same text with its unmatched matchers escaped.
+type EscapeReader struct {
+	r   io.Reader
+	f   unmatchedFilter
+	out *escapeSink
+	in  []byte // buffer for reading from r
+	err error  // error from r, reported once output is exhausted
+}
+
+// NewEscapeReader creates an EscapeReader that reads text from r
+// and escapes it using the escape dictionary e
+// and the Standard configuration.
+func NewEscapeReader(r io.Reader, e Escapes) *EscapeReader {
+	return Standard.NewEscapeReader(r, e)
+}
+
+// NewEscapeReader is like the NewEscapeReader function
+// but escapes the unmatched matchers of configuration c.
+// It panics if e lacks an escape for any matcher of c.
+func (c *Config) NewEscapeReader(r io.Reader, e Escapes) *EscapeReader {
+	s := newEscapeSink(c, e)
+	return &EscapeReader{r: r, f: unmatchedFilter{cfg: c, out: s}, out: s}
+}
+
+// Read reads escaped text into p.
+func (er *EscapeReader) Read(p []byte) (int, error) {
+	for len(er.out.buf) == 0 {
+		if er.err != nil {
+			return 0, er.err
+		}
+		er.fill()
+	}
+	n := copy(p, er.out.buf)
+	er.out.buf = er.out.buf[n:]
+	return n, nil
+}
+
+// Read and escape the next chunk of the underlying reader.
+func (er *EscapeReader) fill() {
+	if er.in == nil {
+		er.in = make([]byte, 4096)
+	}
+	er.out.buf = er.out.buf[:0]
+	n, err := er.r.Read(er.in)
+	if n > 0 {
+		if ferr := er.f.write(er.in[:n]); ferr != nil {
+			err = ferr
+		}
+	}
+	if err == io.EOF {
+		if ferr := er.f.close(); ferr != nil {
+			err = ferr
+		}
+	}
+	er.err = err
+}
+
+// escapeSink accumulates escaped text in a buffer.
+type escapeSink struct {
+	e      Escapes
+	always [256]bool // bytes to escape wherever they appear
+	buf    []byte
+}
+
+func newEscapeSink(c *Config, e Escapes) *escapeSink {
+	s := &escapeSink{e: e}
+	for i := 0; i < len(c.pairs); i++ {
+		if _, ok := e[c.pairs[i]]; !ok {
+			panic(fmt.Sprintf("matchertext: no escape for matcher %q",
+				c.pairs[i]))
+		}
+	}
+	for b := range e {
+		s.always[b] = !c.IsMatcher(b) && !IsMatcher(b)
+	}
+	return s
+}
+
+func (s *escapeSink) text(p []byte) error {
+	l := 0
+	for i, b := range p {
+		if s.always[b] {
+			s.buf = append(s.buf, p[l:i]...)
+			s.buf = append(s.buf, s.e[b]...)
+			l = i + 1
+		}
+	}
+	s.buf = append(s.buf, p[l:]...)
+	return nil
+}
+
+func (s *escapeSink) unmatched(b byte) error {
+	s.buf = append(s.buf, s.e[b]...)
+	return nil
+}
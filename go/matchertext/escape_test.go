@@ -0,0 +1,110 @@
+package matchertext
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+var escapeTests = []struct {
+	e   Escapes
+	in  string
+	out string
+}{
+	{MinMLEscapes, "", ""},
+	{MinMLEscapes, "a(b)c", "a(b)c"},
+	{MinMLEscapes, "[0,1)", "[[<]]0,1[(>)]"},
+	{MinMLEscapes, "(]", "[(<)][[>]]"},
+	{XMLEscapes, "f(x) & g[y", "f(x) &amp; g&#91;y"},
+	{XMLEscapes, "}{", "&#125;&#123;"},
+	{PercentEscapes, "100% (a", "100%25 %28a"},
+	{PercentEscapes, "{a]}", "%7Ba%5D%7D"},
+	{CEscapes, `\n)`, `\\n\x29`},
+	{CEscapes, "(a[b)c]", `(a\x5Bb)c\x5D`},
+}
+
+func TestEscapeWriter(t *testing.T) {
+	for i, et := range escapeTests {
+		var sb strings.Builder
+		ew := NewEscapeWriter(&sb, et.e)
+		if _, err := io.WriteString(ew, et.in); err != nil {
+			t.Errorf("%v write error %v", i, err)
+		}
+		if err := ew.Close(); err != nil {
+			t.Errorf("%v close error %v", i, err)
+		}
+		if sb.String() != et.out {
+			t.Errorf("%v expecting %q got %q", i, et.out, sb.String())
+		}
+	}
+}
+
+func TestEscapeReader(t *testing.T) {
+	for i, et := range escapeTests {
+		er := NewEscapeReader(&chunkReader{et.in, 2}, et.e)
+		b, err := io.ReadAll(er)
+		if err != nil {
+			t.Errorf("%v read error %v", i, err)
+		}
+		if string(b) != et.out {
+			t.Errorf("%v expecting %q got %q", i, et.out, b)
+		}
+	}
+}
+
+func TestEscapeConfig(t *testing.T) {
+	var sb strings.Builder
+	ew := Braces.NewEscapeWriter(&sb, XMLEscapes)
+	io.WriteString(ew, "[0,1) {")
+	ew.Close()
+	if s := sb.String(); s != "[0,1) &#123;" {
+		t.Errorf("got %q", s)
+	}
+
+	// An escape dictionary must cover every matcher
+	defer func() {
+		if recover() == nil {
+			t.Errorf("missing escape did not panic")
+		}
+	}()
+	NewConfig("<>").NewEscapeWriter(&sb, XMLEscapes)
+}
+
+// Escaping random text must always yield valid matchertext,
+// and must not disturb text that is already valid.
+func TestEscapeRandom(t *testing.T) {
+	rng := rand.New(rand.NewSource(9))
+	dicts := []Escapes{MinMLEscapes, XMLEscapes, PercentEscapes, CEscapes}
+	for i := 0; i < 1000; i++ {
+		s := randString(rng, "ab()[]{}", rng.Intn(50))
+		e := dicts[rng.Intn(len(dicts))]
+
+		var w bytes.Buffer
+		ew := NewEscapeWriter(&w, e)
+		for r := (&chunkReader{s, 1 + rng.Intn(4)}); ; {
+			buf := make([]byte, 8)
+			n, err := r.Read(buf)
+			ew.Write(buf[:n])
+			if err != nil {
+				break
+			}
+		}
+		ew.Close()
+
+		os, _ := UnmatchedOffsets(bytes.NewReader(w.Bytes()))
+		if len(os) != 0 {
+			t.Errorf("%q escaped to invalid %q", s, w.String())
+		}
+		um, _ := UnmatchedOffsets(strings.NewReader(s))
+		if len(um) == 0 && w.String() != s {
+			t.Errorf("%q altered to %q", s, w.String())
+		}
+
+		b, _ := io.ReadAll(NewEscapeReader(strings.NewReader(s), e))
+		if string(b) != w.String() {
+			t.Errorf("%q reader %q writer %q", s, b, w.String())
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package matchertext
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// Extract finds the embedded matchertext starting with the opener
+// at offset start in src, according to the Standard configuration.
+// See Config.Extract for details.
+func Extract(src []byte, start int) (end int, err error) {
+	return Standard.Extract(src, start)
+}
+
+// Extract finds the embedded matchertext starting with the opener
+// at offset start in src, according to configuration c,
+// and returns the offset of its matching closer.
+// The embedded matchertext is thus src[start+1:end],
+// and the complete pair src[start:end+1].
+//
+// A host language typically calls Extract on finding an escape
+// such as \[, %[, +[, or <![MDATA[ whose last byte is the opener,
+// so that it need not parse the embedded matchertext itself.
+// The alphabet of c does not affect the result.
+//
+// Extract requires the embedded matchertext to be valid,
+// and returns -1 and a SyntaxError if it is not:
+// of kind ExpectedOpener if src[start] is not an opener,
+// MismatchedCloser if an opener is closed by a mismatched closer,
+// or UnmatchedOpener if src ends before the pair does.
+// The SyntaxError's offsets are relative to the start of src.
+//
+//matchertext:allow
+func (c *Config) Extract(src []byte, start int) (end int, err error) {
+	if start < 0 || start >= len(src) || !c.IsOpener(src[start]) {
+		return -1, srcError(src, ExpectedOpener, "expecting opener",
+			start, start+1)
+	}
+	stack := []int{start} // offsets of pending openers
+	for i := start + 1; i < len(src); i++ {
+		i += indexMatcher(c, src[i:])
+		if i == len(src) {
+			break
+		}
+		b := src[i]
+		if c.IsOpener(b) {
+			stack = append(stack, i)
+			continue
+		}
+		o := stack[len(stack)-1]
+		if !c.IsMatched(src[o], b) {
+			return -1, srcError(src, MismatchedCloser, fmt.Sprintf(
+				"opener %v closed with mismatched %v",
+				string(src[o]), string(b)), o, i+1)
+		}
+		stack = stack[:len(stack)-1]
+		if len(stack) == 0 {
+			return i, nil
+		}
+	}
+	o := stack[len(stack)-1]
+	return -1, srcError(src, UnmatchedOpener, fmt.Sprintf(
+		"unmatched opener %v", string(src[o])), o, o+1)
+}
+
+// Create a SyntaxError spanning src[ofs:end],
+// counting its line and byte column from the start of src.
+func srcError(src []byte, kind ErrorKind, msg string,
+	ofs, end int) *SyntaxError {
+
+	ofs = min(max(ofs, 0), len(src))
+	end = min(max(end, ofs), len(src))
+	line := 1 + bytes.Count(src[:ofs], []byte{'\n'})
+	col := ofs - bytes.LastIndexByte(src[:ofs], '\n')
+	return &SyntaxError{kind, msg, int64(ofs), int64(end), line, col}
+}
+
+// Extract reads a matcher pair from the parser's input,
+// starting with an opener at the next byte,
+// and writes the embedded matchertext between the opener and its closer,
+// exactly as it appears in the input, to w.
+// Returns the offsets of the opener and its closer in the input.
+//
+// Extract is the streaming counterpart of the Extract function,
+// and likewise requires the embedded matchertext to be valid.
+// Extract returns errors in the pair structure directly
+// rather than via HandleError,
+// since recovering from them would not yield the exact embedded text.
+// Alphabet and policy violations still go to HandleError as usual,
+// and any byte it accepts is written to w unchanged.
+// On finding a mismatched closer, Extract leaves it unconsumed.
+// The parser's Policy, Limits, and Context all apply,
+// and Extract does not recurse for nested pairs.
+func (p *Parser) Extract(w io.Writer) (start, end int64, err error) {
+	cfg := p.config()
+	b, err := p.getc()
+	if err == nil && !cfg.IsOpener(b) {
+		p.ungetc(b)
+		err = p.SyntaxErrorKind(ExpectedOpener, "expecting opener")
+	}
+	if err == io.EOF {
+		err = p.SyntaxErrorKind(ExpectedOpener, "expecting opener")
+	}
+	if err != nil {
+		return -1, -1, err
+	}
+
+	type pending struct {
+		o         byte
+		ofs       int64
+		line, col int
+	}
+	stack := []pending{{b, p.ofs, p.line, p.col}}
+	start = p.ofs
+	defer func(depth int) { p.depth = depth }(p.depth)
+	if err := p.enterPair(); err != nil {
+		return -1, -1, err
+	}
+
+	x := extractor{w: w}
+	for {
+		if err := p.readBytes(&x); err != nil {
+			return -1, -1, err
+		}
+		b, err := p.getc()
+		if err == io.EOF {
+			top := stack[len(stack)-1]
+			return -1, -1, &SyntaxError{UnmatchedOpener, fmt.Sprintf(
+				"unmatched opener %v", string(top.o)),
+				top.ofs, top.ofs + 1, top.line, top.col}
+		}
+		if err != nil {
+			return -1, -1, err
+		}
+
+		switch cl := cfg.class[b]; {
+		case cl&clOpener != 0:
+			if err := p.enterPair(); err != nil {
+				return -1, -1, err
+			}
+			stack = append(stack, pending{b, p.ofs, p.line, p.col})
+
+		case cl&clCloser != 0:
+			top := stack[len(stack)-1]
+			if !cfg.IsMatched(top.o, b) {
+				p.ungetc(b)
+				return -1, -1, &SyntaxError{MismatchedCloser,
+					fmt.Sprintf("opener %v closed with mismatched %v",
+						string(top.o), string(b)),
+					top.ofs, p.ofs + 1, top.line, top.col}
+			}
+			stack = stack[:len(stack)-1]
+			p.leavePair()
+			if len(stack) == 0 {
+				return start, p.ofs, x.flush()
+			}
+		}
+		if err := x.Byte(b); err != nil {
+			return -1, -1, err
+		}
+	}
+}
+
+// extractor buffers the embedded matchertext Parser.Extract writes.
+// It is a BytesHandler so that Extract can use the parser's fast path.
+type extractor struct {
+	w   io.Writer
+	buf []byte
+}
+
+func (x *extractor) Byte(b byte) error {
+	x.buf = append(x.buf, b)
+	if len(x.buf) >= 4096 {
+		return x.flush()
+	}
+	return nil
+}
+
+func (x *extractor) Open(o, c byte) error {
+	return x.Byte(o)
+}
+
+func (x *extractor) Bytes(b []byte) error {
+	x.buf = append(x.buf, b...)
+	if len(x.buf) >= 4096 {
+		return x.flush()
+	}
+	return nil
+}
+
+func (x *extractor) flush() error {
+	_, err := x.w.Write(x.buf)
+	x.buf = x.buf[:0]
+	return err
+}
@@ -0,0 +1,109 @@
+package matchertext
+
+import (
+	"bufio"
+	"errors"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+var extractTests = []struct {
+	src   string
+	start int
+	end   int       // -1 if an error is expected
+	kind  ErrorKind // kind of the expected error
+}{
+	{`x = "\[a]b"`, 6, 8, ""},
+	{`%[a(b)c{}]%`, 1, 9, ""},
+	{`+[ [x] ]] tail`, 1, 7, ""},
+	{`<![MDATA[a<b>[c]]]>`, 8, 16, ""},
+	{`\[a(b]`, 1, -1, MismatchedCloser},
+	{`\[a(b)`, 1, -1, UnmatchedOpener},
+	{`\[a(b)`, 0, -1, ExpectedOpener},
+	{`\[`, 2, -1, ExpectedOpener},
+}
+
+// Return true if err is of the given kind, or nil if kind is empty.
+func isKind(err error, kind ErrorKind) bool {
+	if kind == "" {
+		return err == nil
+	}
+	return errors.Is(err, kind)
+}
+
+func TestExtract(t *testing.T) {
+	for i, et := range extractTests {
+		end, err := Extract([]byte(et.src), et.start)
+		if end != et.end || !isKind(err, et.kind) {
+			t.Errorf("%v %q: expected %v %v got %v %v",
+				i, et.src, et.end, et.kind, end, err)
+		}
+	}
+}
+
+func TestParserExtract(t *testing.T) {
+	for i, et := range extractTests {
+		p := NewParser(strings.NewReader(et.src[et.start:]))
+		var sb strings.Builder
+		start, end, err := p.Extract(&sb)
+		if !isKind(err, et.kind) {
+			t.Errorf("%v %q: expected %v got %v", i, et.src, et.kind, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		want := et.src[et.start+1 : et.end]
+		if start != 0 || end != int64(et.end-et.start) ||
+			sb.String() != want {
+			t.Errorf("%v %q: got %v-%v %q", i, et.src,
+				start, end, sb.String())
+		}
+
+		// The parser must leave the text following the pair unread
+		var rest []byte
+		for b, err := p.ReadByte(); err == nil; b, err = p.ReadByte() {
+			rest = append(rest, b)
+		}
+		if string(rest) != et.src[et.end+1:] {
+			t.Errorf("%v %q: left %q unread", i, et.src, rest)
+		}
+	}
+}
+
+// The slice and streaming versions must agree with the Index.
+func TestExtractRandom(t *testing.T) {
+	rng := rand.New(rand.NewSource(18))
+	for i := 0; i < 1000; i++ {
+		s := "(" + randString(rng, "ab\n()[]{}", rng.Intn(40))
+		end, err := Extract([]byte(s), 0)
+
+		// Extract succeeds exactly when the opener's content is valid
+		x := NewIndex([]byte(s))
+		want := x.Match(0)
+		if want >= 0 {
+			for _, o := range x.Unmatched() {
+				if o < int64(want) {
+					want = -1
+				}
+			}
+		}
+		if end != want || (err == nil) != (want >= 0) {
+			t.Errorf("%q: expected %v got %v %v", s, want, end, err)
+		}
+
+		var sb strings.Builder
+		p := NewParser(bufio.NewReaderSize(strings.NewReader(s), 16))
+		_, pend, perr := p.Extract(&sb)
+		if int(pend) != end || (perr == nil) != (err == nil) {
+			t.Errorf("%q: parser got %v %v", s, pend, perr)
+		}
+		if perr == nil && sb.String() != s[1:end] {
+			t.Errorf("%q: parser extracted %q", s, sb.String())
+		}
+		if perr != nil && perr.Error() != err.Error() {
+			t.Errorf("%q: error %v expected %v", s, perr, err)
+		}
+	}
+}
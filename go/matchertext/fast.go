@@ -0,0 +1,117 @@
+package matchertext
+
+import (
+	"bufio"
+	"bytes"
+	"math/bits"
+)
+
+// BytesHandler is an optional extension to Handler
+// that accepts whole runs of non-matcher bytes at once.
+//
+// When the parser's input is buffered and its alphabet is unrestricted,
+// ReadText passes runs of non-matcher bytes to Bytes
+// instead of passing them one at a time to Byte.
+// The byte slice is valid only until Bytes returns or re-invokes the parser,
+// and Bytes must not retain it.
+// Bytes may return a non-nil error to cease parsing,
+// in which case the run is nonetheless consumed.
+type BytesHandler interface {
+	Handler
+	Bytes(b []byte) error // handle a run of non-matcher bytes
+}
+
+const (
+	lsbs = 0x0101010101010101 // least-significant bit of every byte
+	msbs = 0x8080808080808080 // most-significant bit of every byte
+
+	// Maximum number of matchers for which word-at-a-time search pays off
+	maxWordMatchers = 8
+)
+
+// Return the index of the first matcher of configuration c in src,
+// or len(src) if src contains no matchers.
+//
+// Searches eight bytes at a time using SIMD-within-a-register techniques:
+// for each matcher m, a word whose bytes are XORed with m
+// contains a zero byte exactly where m appears,
+// and (x - lsbs) &^ x & msbs sets the high bit of the first zero byte in x.
+func indexMatcher[S []byte | string](c *Config, src S) int {
+	i := 0
+	if len(c.words) <= maxWordMatchers {
+		for ; i+8 <= len(src); i += 8 {
+			w := uint64(src[i]) | uint64(src[i+1])<<8 |
+				uint64(src[i+2])<<16 | uint64(src[i+3])<<24 |
+				uint64(src[i+4])<<32 | uint64(src[i+5])<<40 |
+				uint64(src[i+6])<<48 | uint64(src[i+7])<<56
+			var t uint64
+			for _, m := range c.words {
+				x := w ^ m
+				t |= (x - lsbs) &^ x & msbs
+			}
+			if t != 0 {
+				return i + bits.TrailingZeros64(t)/8
+			}
+		}
+	}
+
+	// Check any remaining bytes individually
+	for ; i < len(src); i++ {
+		if c.class[src[i]]&clMatcher != 0 {
+			return i
+		}
+	}
+	return i
+}
+
+// Consume the run of non-matcher bytes already buffered in br, if any,
+// up to limit bytes, and return the number of bytes consumed.
+func skipText(c *Config, br *bufio.Reader, limit int) int {
+	buf, _ := br.Peek(min(br.Buffered(), limit))
+	n := indexMatcher(c, buf)
+	br.Discard(n)
+	return n
+}
+
+// Pass the run of non-matcher bytes already buffered in the parser's input,
+// if any, to handler h at once.
+// Does nothing if the parser cannot currently use this fast path.
+func (p *Parser) readBytes(h BytesHandler) error {
+	if p.br == nil || p.b >= 0 || p.Policy != 0 || p.config().banned {
+		return nil
+	}
+	next := p.ofs // offset of the first byte of the run
+	if p.last >= 0 {
+		next++
+	}
+	buf, _ := p.br.Peek(min(p.br.Buffered(), p.uncheckedBytes(next)))
+	n := indexMatcher(p.config(), buf)
+	if n == 0 {
+		return nil
+	}
+	run := buf[:n]
+
+	// Advance our logical position to the last byte of the run,
+	// as if we had read the run one byte at a time.
+	p.step()
+	p.ofs += int64(n - 1)
+	rest := run[:n-1]
+	if nl := bytes.Count(rest, []byte{'\n'}); nl > 0 {
+		p.line += nl
+		p.col = 1
+		rest = rest[bytes.LastIndexByte(rest, '\n')+1:]
+	}
+	if p.Columns == ByteColumns {
+		p.col += len(rest)
+	} else {
+		tab := tabWidth(p.TabWidth)
+		for _, b := range rest {
+			p.col = p.Columns.advance(p.col, b, tab)
+		}
+	}
+	p.last = int(run[n-1])
+
+	// Consume the run, which remains in the buffer until the next read.
+	p.br.Discard(n)
+	return h.Bytes(run)
+}
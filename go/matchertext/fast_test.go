@@ -0,0 +1,123 @@
+package matchertext
+
+import (
+	"bufio"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// Generate a random string drawn mostly from the given alphabet.
+func randString(rng *rand.Rand, alphabet string, n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = alphabet[rng.Intn(len(alphabet))]
+	}
+	return string(b)
+}
+
+func TestIndexMatcher(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	cfgs := []*Config{Standard, Braces, NewConfig(""),
+		NewConfig("()[]{}<>\x80\x81\xfe\xff")}
+	for _, c := range cfgs {
+		for i := 0; i < 1000; i++ {
+			s := randString(rng, "abc \n\x00\x80\xfe\xff()[]{}<>",
+				rng.Intn(40))
+			s = strings.Repeat("xyzzy", rng.Intn(5)) + s
+
+			want := len(s)
+			for j := 0; j < len(s); j++ {
+				if c.IsMatcher(s[j]) {
+					want = j
+					break
+				}
+			}
+			if got := indexMatcher(c, s); got != want {
+				t.Errorf("%q in %q: expected %v got %v",
+					c.Pairs(), s, want, got)
+			}
+			if got := indexMatcher(c, []byte(s)); got != want {
+				t.Errorf("%q in %q: []byte version got %v",
+					c.Pairs(), s, got)
+			}
+		}
+	}
+}
+
+// testBytesHandler is a testHandler that also accepts runs of bytes.
+type testBytesHandler struct {
+	testHandler
+	runs int
+}
+
+func (h *testBytesHandler) Bytes(b []byte) error {
+	h.runs++
+	_, err := h.sb.Write(b)
+	return err
+}
+
+func (h *testBytesHandler) Open(o, c byte) error {
+	h.sb.WriteByte('<')
+	if err := h.p.ReadPair(h, o, c); err != nil {
+		return err
+	}
+	return h.sb.WriteByte('>')
+}
+
+func TestParserBytes(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	runs := 0
+	for i := 0; i < 500; i++ {
+		s := randString(rng, "abcdefgh\n\n(([[{{}}]])", rng.Intn(100))
+
+		// Parse byte-at-a-time, then in batches from a small buffer,
+		// and make sure we get the same output and error positions.
+		var errs1, errs2 []string
+		h1 := &testHandler{}
+		h1.p = NewParser(strings.NewReader(s))
+		h1.p.HandleError = func(err error) error {
+			errs1 = append(errs1, err.Error())
+			return nil
+		}
+		h1.p.ReadAll(h1)
+
+		h2 := &testBytesHandler{}
+		r := bufio.NewReaderSize(strings.NewReader(s), 16)
+		h2.p = NewParser(r)
+		h2.p.HandleError = func(err error) error {
+			errs2 = append(errs2, err.Error())
+			return nil
+		}
+		h2.p.ReadAll(h2)
+		runs += h2.runs
+
+		if h1.sb.String() != h2.sb.String() ||
+			strings.Join(errs1, ";") != strings.Join(errs2, ";") {
+			t.Errorf("%q: byte parse %q %v, batch parse %q %v",
+				s, h1.sb.String(), errs1, h2.sb.String(), errs2)
+		}
+		if h1.p.Offset() != h2.p.Offset() {
+			t.Errorf("%q: final offset %v vs %v",
+				s, h1.p.Offset(), h2.p.Offset())
+		}
+	}
+	if runs == 0 {
+		t.Errorf("fast path never used")
+	}
+}
+
+func TestUnmatchedOffsetsBuffered(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	for i := 0; i < 500; i++ {
+		s := randString(rng, "abcdefgh()[]{}", rng.Intn(100))
+		os1, _ := UnmatchedOffsets(strings.NewReader(s))
+		os2, _ := UnmatchedOffsets(
+			bufio.NewReaderSize(strings.NewReader(s), 16))
+		os1.Sort()
+		os2.Sort()
+		if !eqOffsetSlice(os1, os2) {
+			t.Errorf("%q: expected %v got %v", s, os1, os2)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package matchertext
+
+import (
+	"fmt"
+	"io"
+)
+
+// FlatHandler is an interface representing client logic
+// to handle matchertext without recursion, using Parser.ReadFlat.
+//
+// The parser invokes Byte on each non-matcher byte,
+// Open on the opener o of each pair, whose matching closer is c,
+// and Close when that pair ends.
+// Every Open is followed eventually by the corresponding Close,
+// with Open and Close calls nesting properly,
+// unless parsing stops early due to an error.
+type FlatHandler interface {
+	Byte(b byte) error     // handle any non-matcher byte
+	Open(o, c byte) error  // handle the opener of a pair
+	Close(o, c byte) error // handle the end of a pair
+}
+
+// ReadFlat parses a matchertext stream until end-of-file or another error,
+// reporting its structure to h as a flat sequence of calls.
+// Unlike ReadAll and ReadPair, which recurse once per nesting level,
+// ReadFlat uses an explicit stack of pending openers,
+// so deeply nested input costs only a few bytes of memory per level.
+// The parser's Limits.MaxDepth, if set, bounds this stack.
+//
+// ReadFlat handles syntax errors exactly as ReadAll does
+// when each Open handler invokes ReadPair:
+// it reports each error to the parser's HandleError function, if any,
+// and recovers if HandleError accepts the error.
+// A pair whose opener is left unclosed at end-of-file
+// or closed by a mismatched closer is closed implicitly,
+// with a Close call at the point of the error.
+//
+// Since a FlatHandler also satisfies Handler,
+// if h also implements BytesHandler,
+// ReadFlat uses its Bytes method to handle runs of non-matcher bytes
+// when it can.
+//
+// Returns nil on successful parsing until end-of-file (EOF).
+func (p *Parser) ReadFlat(h FlatHandler) error {
+	cfg := p.config()
+	bh, batch := h.(BytesHandler)
+
+	type pending struct {
+		o         byte  // the opener
+		ofs       int64 // position of the opener
+		line, col int
+	}
+	var stack []pending
+	defer func(depth int) { p.depth = depth }(p.depth)
+
+	for {
+		// Handle any buffered run of non-matchers in one batch if we can
+		if batch {
+			if e := p.readBytes(bh); e != nil {
+				return e
+			}
+		}
+
+		b, e := p.getc()
+		if e == io.EOF {
+			break
+		}
+		if e != nil {
+			return e
+		}
+
+		switch cl := cfg.class[b]; {
+		case cl&clOpener != 0:
+			if e := p.enterPair(); e != nil {
+				return e
+			}
+			stack = append(stack, pending{b, p.ofs, p.line, p.col})
+			e = h.Open(b, cfg.closer[b])
+
+		case cl&clCloser != 0 && len(stack) == 0:
+			e = p.handleError(p.SyntaxErrorKind(UnmatchedCloser,
+				fmt.Sprintf("unmatched closer %v", string(b))))
+
+		case cl&clCloser != 0:
+			top := stack[len(stack)-1]
+			c := cfg.closer[top.o]
+			if b != c {
+				// Close the pair implicitly and re-examine the closer
+				e = p.handleError(&SyntaxError{MismatchedCloser,
+					fmt.Sprintf("opener %v closed with mismatched %v",
+						string(top.o), string(b)),
+					top.ofs, p.ofs + 1, top.line, top.col})
+				if e != nil {
+					return e
+				}
+				p.ungetc(b)
+			}
+			stack = stack[:len(stack)-1]
+			p.leavePair()
+			e = h.Close(top.o, c)
+
+		default:
+			e = h.Byte(b)
+		}
+		if e != nil {
+			return e
+		}
+	}
+
+	// Close any pairs still open at end-of-file, innermost first
+	for len(stack) > 0 {
+		top := stack[len(stack)-1]
+		e := p.handleError(&SyntaxError{UnmatchedOpener,
+			fmt.Sprintf("unmatched opener %v", string(top.o)),
+			top.ofs, top.ofs + 1, top.line, top.col})
+		if e != nil {
+			return e
+		}
+		stack = stack[:len(stack)-1]
+		p.leavePair()
+		if e := h.Close(top.o, cfg.closer[top.o]); e != nil {
+			return e
+		}
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package matchertext
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// flatHandler records the bytes and pairs it encounters
+// in the same form as testHandler.
+type flatHandler struct {
+	sb strings.Builder
+}
+
+func (h *flatHandler) Byte(b byte) error {
+	return h.sb.WriteByte(b)
+}
+
+func (h *flatHandler) Open(o, c byte) error {
+	return h.sb.WriteByte('<')
+}
+
+func (h *flatHandler) Close(o, c byte) error {
+	return h.sb.WriteByte('>')
+}
+
+func (h *flatHandler) String() string {
+	return h.sb.String()
+}
+
+// flatBytesHandler is a flatHandler that also accepts runs of bytes.
+type flatBytesHandler struct {
+	flatHandler
+}
+
+func (h *flatBytesHandler) Bytes(b []byte) error {
+	_, err := h.sb.Write(b)
+	return err
+}
+
+// ReadFlat must produce the same output and errors as recursive parsing.
+func TestReadFlat(t *testing.T) {
+	rng := rand.New(rand.NewSource(15))
+	for i := 0; i < 1000; i++ {
+		s := randString(rng, "ab\n()[]{}", rng.Intn(60))
+
+		var errs1, errs2 []string
+		h1 := &testHandler{}
+		h1.p = NewParser(strings.NewReader(s))
+		h1.p.HandleError = func(err error) error {
+			errs1 = append(errs1, err.Error())
+			return nil
+		}
+		if err := h1.p.ReadAll(h1); err != nil {
+			t.Fatal(err)
+		}
+
+		var h2 interface {
+			FlatHandler
+			String() string
+		}
+		var r io.Reader = strings.NewReader(s)
+		if i%2 == 0 {
+			h2 = &flatHandler{}
+		} else {
+			h2 = &flatBytesHandler{}
+			r = bufio.NewReaderSize(r, 16)
+		}
+		p := NewParser(r)
+		p.HandleError = func(err error) error {
+			errs2 = append(errs2, err.Error())
+			return nil
+		}
+		if err := p.ReadFlat(h2); err != nil {
+			t.Fatal(err)
+		}
+
+		if h1.sb.String() != h2.String() {
+			t.Errorf("%q: expected %q got %q", s, h1.sb.String(), h2)
+		}
+		if fmt.Sprint(errs1) != fmt.Sprint(errs2) {
+			t.Errorf("%q: expected errors %v got %v", s, errs1, errs2)
+		}
+	}
+}
+
+func TestReadFlatDeep(t *testing.T) {
+	src := nested(1000000)
+	p := NewParser(strings.NewReader(string(src)))
+	if err := p.ReadFlat(&flatHandler{}); err != nil {
+		t.Errorf("deep nesting: %v", err)
+	}
+
+	p = NewParser(strings.NewReader(string(src)))
+	p.Limits.MaxDepth = 1000
+	if err := p.ReadFlat(&flatHandler{}); !errors.Is(err, ErrMaxDepth) {
+		t.Errorf("expected depth limit, got %v", err)
+	}
+}
+
+func BenchmarkReadFlatNesting(b *testing.B) {
+	for _, depth := range nestingDepths {
+		src := string(nested(depth))
+		b.Run(fmt.Sprintf("ReadAll/%v", depth), func(b *testing.B) {
+			benchNesting(b, depth, func() {
+				h := &testHandler{}
+				h.p = NewParser(strings.NewReader(src))
+				h.p.ReadAll(h)
+			})
+		})
+		b.Run(fmt.Sprintf("ReadFlat/%v", depth), func(b *testing.B) {
+			benchNesting(b, depth, func() {
+				NewParser(strings.NewReader(src)).
+					ReadFlat(&flatHandler{})
+			})
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package matchertext
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+// Add seeds for the fuzz tests from the matchertext test corpus,
+// both whole and line by line, since single lines of a valid document
+// often contain unmatched matchers.
+func addCorpusSeeds(f *testing.F, add func(src []byte)) {
+	for _, ut := range unmatchedTests {
+		add([]byte(ut.s))
+	}
+	src, err := os.ReadFile("../../test/index.m")
+	if err != nil {
+		f.Fatal(err)
+	}
+	add(src)
+	for _, line := range bytes.Split(src, []byte("\n")) {
+		add(line)
+	}
+}
+
+// Collect the offsets of the syntax errors reporting unmatched matchers,
+// ignoring any other errors, such as bytes outside the alphabet.
+func unmatchedErrors(os *OffsetSlice) func(error) error {
+	return func(err error) error {
+		se, ok := err.(*SyntaxError)
+		if !ok {
+			return err
+		}
+		switch se.Kind() {
+		case UnmatchedOpener, UnmatchedCloser, MismatchedCloser:
+			*os = append(*os, se.Offset())
+		}
+		return nil
+	}
+}
+
+// The independent implementations of the unmatched-matcher rule
+// must all agree on which matchers are unmatched:
+// the UnmatchedOffsets scan, the Unmatched mask and its streaming version,
+// the syntax errors of ReadAll and ReadFlat, Scan, and Index.
+func FuzzUnmatched(f *testing.F) {
+	addCorpusSeeds(f, func(src []byte) {
+		f.Add(src, false)
+		f.Add(src, true)
+	})
+	f.Fuzz(func(t *testing.T, src []byte, braces bool) {
+		c := Standard
+		if braces {
+			c = Braces
+		}
+
+		expect, err := c.UnmatchedOffsets(bytes.NewReader(src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect.Sort()
+
+		// The Unmatched mask
+		var os OffsetSlice
+		mask := c.Unmatched(nil, src)
+		for i, b := range mask {
+			if b != 0 {
+				os = append(os, int64(i))
+			}
+		}
+		if !eqOffsetSlice(os, expect) {
+			t.Fatalf("Unmatched found %v, UnmatchedOffsets %v", os, expect)
+		}
+
+		// The streaming mask
+		var sb strings.Builder
+		r := &chunkReader{string(src), 1 + len(src)%7}
+		if err := c.WriteUnmatched(&sb, r); err != nil {
+			t.Fatal(err)
+		}
+		if sb.String() != string(mask) {
+			t.Fatalf("WriteUnmatched mask %q, Unmatched %q", sb.String(), mask)
+		}
+
+		// The recursive parser, without its fast path
+		os = nil
+		h := &testHandler{}
+		h.p = NewParser(bytes.NewReader(src))
+		h.p.Config = c
+		h.p.HandleError = unmatchedErrors(&os)
+		if err := h.p.ReadAll(h); err != nil {
+			t.Fatal(err)
+		}
+		os.Sort()
+		if !eqOffsetSlice(os, expect) {
+			t.Fatalf("ReadAll found %v, UnmatchedOffsets %v", os, expect)
+		}
+
+		// The flat parser, with its fast path
+		os = nil
+		fh := &flatBytesHandler{}
+		p := NewParser(bufio.NewReader(bytes.NewReader(src)))
+		p.Config = c
+		p.HandleError = unmatchedErrors(&os)
+		if err := p.ReadFlat(fh); err != nil {
+			t.Fatal(err)
+		}
+		os.Sort()
+		if !eqOffsetSlice(os, expect) {
+			t.Fatalf("ReadFlat found %v, UnmatchedOffsets %v", os, expect)
+		}
+		if fh.String() != h.sb.String() {
+			t.Fatalf("ReadFlat output %q, ReadAll %q",
+				fh.String(), h.sb.String())
+		}
+
+		// The spans of Scan
+		os = nil
+		for _, sp := range c.Scan(src) {
+			if sp.Kind == UnmatchedSpan {
+				os = append(os, int64(sp.Start))
+			}
+		}
+		os.Sort()
+		if !eqOffsetSlice(os, expect) {
+			t.Fatalf("Scan found %v, UnmatchedOffsets %v", os, expect)
+		}
+
+		// The Index
+		os = append(OffsetSlice(nil), c.NewIndex(src).Unmatched()...)
+		os.Sort()
+		if !eqOffsetSlice(os, expect) {
+			t.Fatalf("Index found %v, UnmatchedOffsets %v", os, expect)
+		}
+	})
+}
+
+// Extract must find exactly the pairs that Index matches,
+// and the streaming Extract must agree with the in-memory one.
+func FuzzExtract(f *testing.F) {
+	addCorpusSeeds(f, func(src []byte) {
+		f.Add(src, uint(bytes.IndexAny(src, "([{")))
+	})
+	f.Fuzz(func(t *testing.T, src []byte, start uint) {
+		if len(src) == 0 {
+			return
+		}
+		i := int(start % uint(len(src)))
+		end, err := Extract(src, i)
+
+		// A valid pair is exactly a matched pair
+		// containing no unmatched matchers.
+		x := NewIndex(src)
+		valid := IsOpener(src[i]) && x.Match(i) >= 0 &&
+			bytes.IndexFunc(Unmatched(nil, src[i:x.Match(i)+1]),
+				func(r rune) bool { return r != 0 }) < 0
+		switch {
+		case valid && (err != nil || end != x.Match(i)):
+			t.Fatalf("Extract at %v: %v, %v, expected %v",
+				i, end, err, x.Match(i))
+		case !valid && err == nil:
+			t.Fatalf("Extract at %v: invalid pair ending at %v", i, end)
+		}
+
+		// The streaming version
+		var sb strings.Builder
+		p := NewParser(bytes.NewReader(src[i:]))
+		ps, pe, perr := p.Extract(&sb)
+		switch {
+		case (perr == nil) != (err == nil):
+			t.Fatalf("Parser.Extract at %v: %v, Extract %v", i, perr, err)
+		case err == nil && (ps != 0 || pe != int64(end-i) ||
+			sb.String() != string(src[i+1:end])):
+			t.Fatalf("Parser.Extract at %v: %v, %v, %q, expected %v",
+				i, ps, pe, sb.String(), end)
+		}
+	})
+}
@@ -0,0 +1,367 @@
+package matchertext
+
+import (
+	"sort"
+)
+
+// Index is a precomputed table of the matched pairs
+// and unmatched matchers in a matchertext source,
+// supporting random-access lookups by byte offset.
+//
+// An Index identifies matched pairs and unmatched matchers
+// exactly as Scan and UnmatchedOffsets do.
+// Offsets are byte offsets within the indexed source.
+//
+// An Index holds the matched pairs as a tree,
+// so a lookup takes time proportional to the nesting depth at its offset
+// and the logarithm of the number of pairs at each level,
+// and Edit need only rebuild the part of the tree an edit disturbs.
+type Index struct {
+	cfg  *Config // configuration the index was built with
+	buf  []byte  // the indexed source, with a gap at buf[gap:end]
+	gap  int     // offset in buf at which the gap starts
+	end  int     // offset in buf at which the gap ends
+	own  bool    // whether buf is a copy the index may modify
+	root pair    // virtual pair enclosing the whole source
+	n    int     // number of matched pairs
+}
+
+// A pair is a node in the tree of matched pairs.
+//
+// Like the source, the offsets within a pair work as a gap buffer:
+// those of the children and unmatched matchers before the pair's split
+// are relative to its opener, and the rest relative to its closer.
+// Edit moves the split of each pair enclosing an edit to the edit,
+// so the edit changes no offsets within the pair
+// but the pair's own size.
+type pair struct {
+	open   int    // opener offset relative to the parent's opener or closer
+	size   int    // closer offset minus opener offset
+	kids   []pair // the pairs directly within this one, in order
+	um     []int  // the unmatched matchers directly within this one
+	ksplit int    // kids[:ksplit] are relative to this pair's opener
+	usplit int    // um[:usplit] are relative to this pair's opener
+}
+
+// The offsets of a matched pair's opener and closer.
+type bounds struct {
+	open, close int
+}
+
+// NewIndex builds an Index of matchertext source src
+// according to the Standard configuration.
+func NewIndex(src []byte) *Index {
+	return Standard.NewIndex(src)
+}
+
+// NewIndex builds an Index of matchertext source src
+// according to configuration c, in a single pass over src.
+// The alphabet of c does not affect the result.
+// The Index retains src, which the caller must not subsequently modify.
+func (c *Config) NewIndex(src []byte) *Index {
+	x := &Index{cfg: c, buf: src, gap: len(src), end: len(src)}
+	ps, um, _ := indexPairs(c, src, 0, 0)
+	x.root = pair{open: -1, size: len(src) + 1}
+	x.root.build(ps, um, -1)
+	x.n = len(ps)
+	return x
+}
+
+// Find the matched pairs and unmatched matchers in src,
+// reporting their offsets relative to base.
+//
+// If closer is nonzero, src is the content of a pair closed by closer,
+// and indexPairs also reports whether that closer would end the content
+// and match the pair's opener, as opposed to some opener or closer in src.
+func indexPairs(c *Config, src []byte, base int, closer byte) (
+	ps []bounds, um OffsetSlice, closes bool) {
+
+	closes = true
+	var stack []int // indexes in ps of openers not yet matched
+	for i := 0; i < len(src); i++ {
+		i += indexMatcher(c, src[i:])
+		if i == len(src) {
+			break
+		}
+		b := src[i]
+
+		// Record each opener as a pair that is unmatched until closed
+		if c.IsOpener(b) {
+			stack = append(stack, len(ps))
+			ps = append(ps, bounds{open: base + i, close: -1})
+			continue
+		}
+
+		// Close the innermost opener this closer matches, if any,
+		// leaving openers closed without matching unmatched.
+		matched := false
+		for len(stack) > 0 && !matched {
+			j := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if c.IsMatched(src[ps[j].open-base], b) {
+				ps[j].close = base + i
+				matched = true
+			}
+		}
+		if !matched {
+			um = append(um, int64(base+i))
+			closes = false // an unmatched closer would end the content
+		}
+	}
+
+	// The closer ending the content would match any pending opener first
+	for _, j := range stack {
+		if c.IsMatched(src[ps[j].open-base], closer) {
+			closes = false
+		}
+	}
+
+	// Drop the openers that turned out to be unmatched
+	n := 0
+	for _, p := range ps {
+		if p.close < 0 {
+			um = append(um, int64(p.open))
+			continue
+		}
+		ps[n] = p
+		n++
+	}
+	um.Sort()
+	return ps[:n], um, closes
+}
+
+// Replace the content of pair p, whose opener is at offset o,
+// with the matched pairs ps and unmatched matchers um found within it.
+//
+// The new descendants of p are allocated together,
+// with the children of each pair adjacent,
+// and likewise their unmatched matchers.
+func (p *pair) build(ps []bounds, um OffsetSlice, o int) {
+
+	// Find the parent of each pair in ps
+	// and the owner of each unmatched matcher,
+	// numbering p itself 0 and each ps[i] i+1,
+	// and count the children and unmatched matchers of each.
+	parent := make([]int, len(ps))
+	owner := make([]int, len(um))
+	nkids := make([]int, len(ps)+1)
+	nums := make([]int, len(ps)+1)
+	var stack []int // numbers of the pairs enclosing the current offset
+	within := func(ofs int) int {
+		for len(stack) > 0 && ps[stack[len(stack)-1]-1].close < ofs {
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) == 0 {
+			return 0
+		}
+		return stack[len(stack)-1]
+	}
+	u := 0
+	for i, b := range ps {
+		for ; u < len(um) && int(um[u]) < b.open; u++ {
+			owner[u] = within(int(um[u]))
+			nums[owner[u]]++
+		}
+		parent[i] = within(b.open)
+		nkids[parent[i]]++
+		stack = append(stack, i+1)
+	}
+	for ; u < len(um); u++ {
+		owner[u] = within(int(um[u]))
+		nums[owner[u]]++
+	}
+
+	// Lay out the children and unmatched matchers of each pair
+	// as consecutive blocks, recording where each block starts.
+	nextKid := make([]int, len(ps)+1)
+	nextUm := make([]int, len(ps)+1)
+	for n := 1; n <= len(ps); n++ {
+		nextKid[n] = nextKid[n-1] + nkids[n-1]
+		nextUm[n] = nextUm[n-1] + nums[n-1]
+	}
+	kids := make([]pair, len(ps))
+	ums := make([]int, len(um))
+	opener := func(n int) int {
+		if n == 0 {
+			return o
+		}
+		return ps[n-1].open
+	}
+
+	// Fill in each pair before its children, and its block of children
+	// in order, while the block of each pair's children is still empty.
+	for i, b := range ps {
+		n := i + 1
+		k, j := nextKid[n], nextUm[n]
+		kids[nextKid[parent[i]]] = pair{
+			open:   b.open - opener(parent[i]),
+			size:   b.close - b.open,
+			kids:   kids[k : k+nkids[n] : k+nkids[n]],
+			um:     ums[j : j+nums[n] : j+nums[n]],
+			ksplit: nkids[n],
+			usplit: nums[n],
+		}
+		nextKid[parent[i]]++
+	}
+	for u, ofs := range um {
+		ums[nextUm[owner[u]]] = int(ofs) - opener(owner[u])
+		nextUm[owner[u]]++
+	}
+
+	p.kids, p.ksplit = kids[:nkids[0]:nkids[0]], nkids[0]
+	p.um, p.usplit = ums[:nums[0]:nums[0]], nums[0]
+}
+
+// Return the offset of the opener of p's child k,
+// where o is the offset of p's own opener.
+func (p *pair) kidOpen(o, k int) int {
+	if k < p.ksplit {
+		return o + p.kids[k].open
+	}
+	return o + p.size + p.kids[k].open
+}
+
+// Return the offset of p's unmatched matcher j,
+// where o is the offset of p's own opener.
+func (p *pair) umOffset(o, j int) int {
+	if j < p.usplit {
+		return o + p.um[j]
+	}
+	return o + p.size + p.um[j]
+}
+
+// Return the index of p's last child whose opener is at or before ofs,
+// or -1 if there is none, where o is the offset of p's own opener.
+func (p *pair) kidAt(o, ofs int) int {
+	return sort.Search(len(p.kids), func(k int) bool {
+		return p.kidOpen(o, k) > ofs
+	}) - 1
+}
+
+// Len returns the number of matched pairs in the index.
+func (x *Index) Len() int {
+	return x.n
+}
+
+// Unmatched returns the sorted offsets of all unmatched matchers.
+// It takes time proportional to the number of matched pairs
+// and unmatched matchers in the index.
+func (x *Index) Unmatched() OffsetSlice {
+	type frame struct {
+		p    *pair
+		o    int // offset of the pair's opener
+		k, j int // next child and unmatched matcher to visit
+	}
+	var um OffsetSlice
+	stack := []frame{{p: &x.root, o: x.root.open}}
+	for len(stack) > 0 {
+		f := &stack[len(stack)-1]
+		switch {
+		case f.j < len(f.p.um) && (f.k == len(f.p.kids) ||
+			f.p.umOffset(f.o, f.j) < f.p.kidOpen(f.o, f.k)):
+			um = append(um, int64(f.p.umOffset(f.o, f.j)))
+			f.j++
+
+		case f.k < len(f.p.kids):
+			kid := frame{p: &f.p.kids[f.k], o: f.p.kidOpen(f.o, f.k)}
+			f.k++
+			stack = append(stack, kid)
+
+		default:
+			stack = stack[:len(stack)-1]
+		}
+	}
+	return um
+}
+
+// Match returns the offset of the matcher matching the one at offset ofs:
+// the closer if ofs is the offset of an opener, and vice versa.
+// Returns -1 if ofs is not the offset of a matched matcher.
+func (x *Index) Match(ofs int) int {
+	p, o := &x.root, x.root.open
+	for {
+		k := p.kidAt(o, ofs)
+		if k < 0 {
+			return -1
+		}
+		ko := p.kidOpen(o, k)
+		kc := ko + p.kids[k].size
+		switch {
+		case ofs == ko:
+			return kc
+		case ofs == kc:
+			return ko
+		case ofs > kc:
+			return -1
+		}
+		p, o = &p.kids[k], ko
+	}
+}
+
+// Enclosing returns the opener offset of the innermost matched pair
+// strictly enclosing offset ofs,
+// or -1 if no pair encloses ofs.
+// A pair does not enclose the offsets of its own opener and closer.
+func (x *Index) Enclosing(ofs int) int {
+	o, _ := x.enclosing(ofs)
+	return o
+}
+
+// Depth returns the number of matched pairs strictly enclosing offset ofs.
+func (x *Index) Depth(ofs int) int {
+	_, depth := x.enclosing(ofs)
+	return depth
+}
+
+// Children returns the opener offsets of the matched pairs
+// nested directly within the pair whose opener is at offset pairOffset,
+// in order.
+// If pairOffset is -1, Children returns the outermost pairs.
+// Returns nil if there are no such pairs,
+// or if pairOffset is not the offset of a matched opener.
+func (x *Index) Children(pairOffset int) []int {
+	p, o := &x.root, x.root.open
+	if pairOffset >= 0 {
+		if p, o = x.opener(pairOffset), pairOffset; p == nil {
+			return nil
+		}
+	}
+
+	var cs []int
+	for k := range p.kids {
+		cs = append(cs, p.kidOpen(o, k))
+	}
+	return cs
+}
+
+// Return the pair whose opener is at offset ofs, or nil.
+func (x *Index) opener(ofs int) *pair {
+	p, o := &x.root, x.root.open
+	for {
+		k := p.kidAt(o, ofs)
+		if k < 0 {
+			return nil
+		}
+		ko := p.kidOpen(o, k)
+		if ko == ofs {
+			return &p.kids[k]
+		}
+		if ko+p.kids[k].size < ofs {
+			return nil
+		}
+		p, o = &p.kids[k], ko
+	}
+}
+
+// Return the opener offset of the innermost pair strictly enclosing ofs,
+// or -1 if there is none, and the number of pairs enclosing ofs.
+func (x *Index) enclosing(ofs int) (o, depth int) {
+	p, o := &x.root, x.root.open
+	for {
+		k := p.kidAt(o, ofs-1)
+		if k < 0 || p.kidOpen(o, k)+p.kids[k].size <= ofs {
+			return o, depth
+		}
+		p, o, depth = &p.kids[k], p.kidOpen(o, k), depth+1
+	}
+}
@@ -0,0 +1,119 @@
+package matchertext
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+func TestIndexUnmatched(t *testing.T) {
+	for i, ut := range unmatchedTests {
+		x := NewIndex([]byte(ut.s))
+		if !eqOffsetSlice(x.Unmatched(), ut.os) {
+			t.Errorf("%v %q: expected %v got %v",
+				i, ut.s, ut.os, x.Unmatched())
+		}
+	}
+}
+
+func TestIndex(t *testing.T) {
+	x := NewIndex([]byte("a(b[c]d{e}f)g)(h[]"))
+	// offsets:         0123456789012345678
+
+	for _, mt := range [][2]int{{1, 11}, {11, 1}, {3, 5}, {5, 3},
+		{7, 9}, {16, 17}, {0, -1}, {13, -1}, {14, -1}, {99, -1}} {
+		if m := x.Match(mt[0]); m != mt[1] {
+			t.Errorf("Match(%v): expected %v got %v", mt[0], mt[1], m)
+		}
+	}
+	for _, et := range [][3]int{{0, -1, 0}, {1, -1, 0}, {2, 1, 1},
+		{3, 1, 1}, {4, 3, 2}, {5, 1, 1}, {11, -1, 0}, {15, -1, 0},
+		{17, -1, 0}} {
+		if e := x.Enclosing(et[0]); e != et[1] {
+			t.Errorf("Enclosing(%v): expected %v got %v",
+				et[0], et[1], e)
+		}
+		if d := x.Depth(et[0]); d != et[2] {
+			t.Errorf("Depth(%v): expected %v got %v", et[0], et[2], d)
+		}
+	}
+	if cs := x.Children(-1); !slices.Equal(cs, []int{1, 16}) {
+		t.Errorf("Children(-1): got %v", cs)
+	}
+	if cs := x.Children(1); !slices.Equal(cs, []int{3, 7}) {
+		t.Errorf("Children(1): got %v", cs)
+	}
+	if cs := x.Children(3); cs != nil {
+		t.Errorf("Children(3): got %v", cs)
+	}
+	if cs := x.Children(2); cs != nil {
+		t.Errorf("Children(2): got %v", cs)
+	}
+	if !eqOffsetSlice(x.Unmatched(), OffsetSlice{13, 14}) || x.Len() != 4 {
+		t.Errorf("wrong unmatched %v or length %v",
+			x.Unmatched(), x.Len())
+	}
+}
+
+// Check an index against brute-force computation from the source's spans.
+func checkIndex(t *testing.T, c *Config, src []byte, x *Index) {
+	t.Helper()
+
+	// Compute the expected partner and depth of every offset
+	match := make([]int, len(src)+1)
+	depth := make([]int, len(src)+1)
+	encl := make([]int, len(src)+1)
+	for i := range match {
+		match[i], encl[i] = -1, -1
+	}
+	var um OffsetSlice
+	for _, sp := range c.Scan(src) {
+		switch sp.Kind {
+		case PairSpan:
+			match[sp.Start], match[sp.End-1] = sp.End-1, sp.Start
+			for i := sp.Start + 1; i < sp.End-1; i++ {
+				depth[i]++
+				encl[i] = sp.Start // innermost pairs come last
+			}
+		case UnmatchedSpan:
+			um = append(um, int64(sp.Start))
+		}
+	}
+
+	for i := 0; i <= len(src); i++ {
+		if m := x.Match(i); m != match[i] {
+			t.Fatalf("%q Match(%v): expected %v got %v",
+				src, i, match[i], m)
+		}
+		if e := x.Enclosing(i); e != encl[i] {
+			t.Fatalf("%q Enclosing(%v): expected %v got %v",
+				src, i, encl[i], e)
+		}
+		if d := x.Depth(i); d != depth[i] {
+			t.Fatalf("%q Depth(%v): expected %v got %v",
+				src, i, depth[i], d)
+		}
+		if match[i] > i && x.Children(i) == nil {
+			for j := i + 1; j < match[i]; j++ {
+				if encl[j] == i && match[j] > j {
+					t.Fatalf("%q Children(%v) missing %v",
+						src, i, j)
+				}
+			}
+		}
+	}
+	if !eqOffsetSlice(x.Unmatched(), um) {
+		t.Fatalf("%q: expected unmatched %v got %v",
+			src, um, x.Unmatched())
+	}
+}
+
+func TestIndexRandom(t *testing.T) {
+	rng := rand.New(rand.NewSource(4))
+	for _, c := range []*Config{Standard, Braces} {
+		for i := 0; i < 500; i++ {
+			src := []byte(randString(rng, "ab()[]{}", rng.Intn(60)))
+			checkIndex(t, c, src, c.NewIndex(src))
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package matchertext
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Limits bounds the resources a Parser may spend on its input,
+// to protect against hostile or endless input streams.
+// A zero value in any field means no limit.
+type Limits struct {
+	MaxDepth int   // Maximum nesting depth of matched pairs
+	MaxBytes int64 // Maximum number of bytes to read
+	MaxPairs int64 // Maximum number of matched pairs to parse
+}
+
+// Errors identifying which limit a LimitError reports.
+var (
+	ErrMaxDepth = errors.New("maximum nesting depth exceeded")
+	ErrMaxBytes = errors.New("maximum input size exceeded")
+	ErrMaxPairs = errors.New("maximum number of pairs exceeded")
+)
+
+// LimitError reports that parsing stopped because the input
+// exceeded one of the parser's Limits.
+// Use errors.Is with ErrMaxDepth, ErrMaxBytes, or ErrMaxPairs
+// to determine which limit was exceeded.
+//
+// Unlike a SyntaxError, a LimitError is never passed to HandleError:
+// it always stops parsing.
+type LimitError struct {
+	Err    error // ErrMaxDepth, ErrMaxBytes, or ErrMaxPairs
+	Limit  int64 // the limit exceeded
+	Offset int64 // byte offset at which the limit was exceeded
+}
+
+// Error returns a human-readable description of the error.
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("offset %v: %v (limit %v)", e.Offset, e.Err, e.Limit)
+}
+
+// Unwrap returns the error identifying which limit was exceeded.
+func (e *LimitError) Unwrap() error {
+	return e.Err
+}
+
+// Number of bytes between checks of the parser's context for cancellation
+const contextInterval = 4096
+
+// Check the parser's byte limit and context
+// on reading the byte at the current offset,
+// and determine the next offset at which to check again.
+func (p *Parser) checkLimits() error {
+	if p.Context != nil {
+		if err := p.Context.Err(); err != nil {
+			return err
+		}
+	}
+	limit := p.Limits.MaxBytes
+	if limit > 0 && p.ofs >= limit {
+		return &LimitError{ErrMaxBytes, limit, p.ofs}
+	}
+
+	// Check periodically even with no limits,
+	// in case the client sets some during parsing.
+	p.check = p.ofs + contextInterval
+	if limit > 0 && limit < p.check {
+		p.check = limit
+	}
+	return nil
+}
+
+// Return the maximum number of bytes we may read before checking limits,
+// starting at offset ofs.
+func (p *Parser) uncheckedBytes(ofs int64) int {
+	if n := p.check - ofs; n < math.MaxInt32 {
+		return int(max(n, 0))
+	}
+	return math.MaxInt32
+}
+
+// Note the start of a new pair within ReadPair,
+// checking the pair's depth and the number of pairs against our limits.
+func (p *Parser) enterPair() error {
+	p.depth++
+	p.pairs++
+	l := &p.Limits
+	if l.MaxDepth > 0 && p.depth > l.MaxDepth {
+		return &LimitError{ErrMaxDepth, int64(l.MaxDepth), p.ofs}
+	}
+	if l.MaxPairs > 0 && p.pairs > l.MaxPairs {
+		return &LimitError{ErrMaxPairs, l.MaxPairs, p.ofs}
+	}
+	return nil
+}
+
+// Note the end of a pair within ReadPair.
+func (p *Parser) leavePair() {
+	p.depth--
+}
@@ -0,0 +1,131 @@
+package matchertext
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+var limitTests = []struct {
+	in     string
+	limits Limits
+	err    error // expected error, nil if none
+	ofs    int64 // offset of the error
+}{
+	{"((()))", Limits{MaxDepth: 3}, nil, 0},
+	{"((()))", Limits{MaxDepth: 2}, ErrMaxDepth, 2},
+	{"()()()", Limits{MaxPairs: 3}, nil, 0},
+	{"()()()()", Limits{MaxPairs: 3}, ErrMaxPairs, 6},
+	{"abcdef", Limits{MaxBytes: 6}, nil, 0},
+	{"abcdefg", Limits{MaxBytes: 6}, ErrMaxBytes, 6},
+	{"a(b)c(d)e", Limits{MaxBytes: 4}, ErrMaxBytes, 4},
+	{strings.Repeat("[", 1000000), Limits{MaxDepth: 100},
+		ErrMaxDepth, 100},
+}
+
+func TestLimits(t *testing.T) {
+	for i, lt := range limitTests {
+		// Try both the byte-at-a-time and buffered fast paths
+		for _, r := range []io.Reader{
+			strings.NewReader(lt.in),
+			bufio.NewReaderSize(strings.NewReader(lt.in), 16),
+		} {
+			h := &testBytesHandler{}
+			h.p = NewParser(r)
+			h.p.Limits = lt.limits
+			h.p.HandleError = func(err error) error { return nil }
+			err := h.p.ReadAll(h)
+			if !errors.Is(err, lt.err) || (err == nil) != (lt.err == nil) {
+				t.Errorf("%v: expected %v, got %v", i, lt.err, err)
+				continue
+			}
+			var le *LimitError
+			if errors.As(err, &le) && le.Offset != lt.ofs {
+				t.Errorf("%v: limit exceeded at %v, expected %v",
+					i, le.Offset, lt.ofs)
+			}
+		}
+	}
+}
+
+func TestUnmatchedOffsetsLimits(t *testing.T) {
+	for i, lt := range limitTests {
+		// Try both the byte-at-a-time and buffered fast paths
+		for _, r := range []io.Reader{
+			strings.NewReader(lt.in),
+			bufio.NewReaderSize(strings.NewReader(lt.in), 16),
+		} {
+			_, err := UnmatchedOffsetsLimits(r, lt.limits)
+			if !errors.Is(err, lt.err) || (err == nil) != (lt.err == nil) {
+				t.Errorf("%v: expected %v, got %v", i, lt.err, err)
+				continue
+			}
+			var le *LimitError
+			if errors.As(err, &le) && le.Offset != lt.ofs {
+				t.Errorf("%v: limit exceeded at %v, expected %v",
+					i, le.Offset, lt.ofs)
+			}
+		}
+	}
+
+	// Offsets found before the limit are still reported
+	os, err := UnmatchedOffsetsLimits(strings.NewReader(")((("),
+		Limits{MaxDepth: 2})
+	if !errors.Is(err, ErrMaxDepth) || !eqOffsetSlice(os, OffsetSlice{0}) {
+		t.Errorf("got %v, %v", os, err)
+	}
+}
+
+// Peeking past MaxBytes must not let the next read escape the limit.
+func TestLimitsPeek(t *testing.T) {
+	p := NewParser(strings.NewReader("abcd"))
+	p.Limits.MaxBytes = 2
+	p.ReadByte()
+	p.ReadByte()
+	if _, err := p.PeekByte(); !errors.Is(err, ErrMaxBytes) {
+		t.Errorf("PeekByte returned %v", err)
+	}
+	if b, err := p.ReadByte(); !errors.Is(err, ErrMaxBytes) {
+		t.Errorf("ReadByte returned %q, %v", b, err)
+	}
+}
+
+// endlessReader yields an endless stream of text.
+type endlessReader struct{}
+
+func (endlessReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 'a'
+	}
+	return len(p), nil
+}
+
+// cancelHandler cancels a context after handling a number of bytes.
+type cancelHandler struct {
+	testHandler
+	n      int
+	cancel func()
+}
+
+func (h *cancelHandler) Byte(b byte) error {
+	if h.n--; h.n == 0 {
+		h.cancel()
+	}
+	return nil
+}
+
+func TestParserContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	h := &cancelHandler{n: 10000, cancel: cancel}
+	h.p = NewParser(endlessReader{})
+	h.p.Context = ctx
+	if err := h.p.ReadAll(h); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected cancellation, got %v", err)
+	}
+	if ofs := h.p.Offset(); ofs < 10000 || ofs > 10000+contextInterval {
+		t.Errorf("cancellation noticed only at offset %v", ofs)
+	}
+}
@@ -0,0 +1,23 @@
+// Package main runs the matchertext analyzer as a standalone command,
+// checking that the string literals and comments in Go packages
+// are valid matchertext.
+//
+// Usage:
+//
+//	go build -o matchertext-lint ./go/matchertext/lint/cmd/
+//	matchertext-lint [-fix] [-pair-escapes] <packages>
+//
+// Examples:
+//
+//	matchertext-lint ./...
+//	matchertext-lint -fix ./go/matchertext/...
+package main
+
+import (
+	"github.com/dedis/matchertext/go/matchertext/lint"
+	"golang.org/x/tools/go/analysis/singlechecker"
+)
+
+func main() {
+	singlechecker.Main(lint.Analyzer)
+}
@@ -0,0 +1,339 @@
+// Package lint defines an Analyzer that checks Go source code
+// for adherence to the matchertext discipline
+// in its string literals and comments.
+//
+// Unmatched matchers most commonly appear in Go code
+// within string literals, such as the open bracket a list parser scans for,
+// and within comments describing such code.
+// The Analyzer reports each such literal or comment
+// that is not valid matchertext according to UnmatchedOffsets,
+// with a suggested fix that escapes its unmatched matchers.
+// The fix for an interpreted string literal
+// replaces each unmatched matcher with a hexadecimal escape like \x5B,
+// and the fix for a raw string literal rewrites it
+// as an equivalent interpreted string literal using such escapes.
+// Since Go has no escapes in comments, the fix for a comment
+// uses the same escapes as a hint to human readers,
+// or if the -pair-escapes flag is set,
+// the paired escapes like \o[] that the matchertext paper proposes.
+//
+// The Analyzer checks each comment group as a unit,
+// so that a matched pair may span the lines of a multi-line comment,
+// and likewise the string literals in each concatenation with the + operator
+// or among the arguments of each call,
+// so that a pair may span them as in "[" + s + "]" or write("[", s, "]").
+// It does not check rune literals,
+// which can hold only a single matcher and so never a matched pair.
+//
+// A //matchertext:allow directive exempts the string literals
+// on the line it ends and the comment group containing it,
+// for code that must deal in unmatched matchers by design.
+// The Analyzer skips generated files.
+//
+//matchertext:allow
+package lint
+
+import (
+	"bytes"
+	"go/ast"
+	"go/token"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/dedis/matchertext/go/matchertext"
+	"golang.org/x/tools/go/analysis"
+)
+
+const doc = `check that string literals and comments are valid matchertext
+
+The matchertext analyzer reports each string literal and each
+comment group containing matchers - parentheses, square brackets, or
+curly braces - that are not part of a matched pair.
+String literals joined with + or passed to the same call
+are checked together, so that a pair may span them.
+Rune literals are not checked.
+A //matchertext:allow comment exempts the string literals on its line
+and the comment group containing it.
+Each report includes a suggested fix that escapes the unmatched matchers:
+in literals as hexadecimal escapes such as \x5B,
+and in comments as the same escapes or, with -pair-escapes,
+as paired escapes such as \o[].`
+
+// Analyzer checks that string literals and comments are valid matchertext.
+var Analyzer = &analysis.Analyzer{
+	Name: "matchertext",
+	Doc:  doc,
+	URL:  "https://pkg.go.dev/github.com/dedis/matchertext/go/matchertext/lint",
+	Run:  run,
+}
+
+// Whether to suggest paired escapes like \o() in comments
+var pairEscapes bool
+
+func init() {
+	Analyzer.Flags.BoolVar(&pairEscapes, "pair-escapes", false,
+		`suggest paired escapes like \o() rather than \x28 in comments`)
+}
+
+// The directive exempting a line from the Analyzer
+const allowDirective = "//matchertext:allow"
+
+func run(pass *analysis.Pass) (any, error) {
+	for _, f := range pass.Files {
+		if ast.IsGenerated(f) {
+			continue
+		}
+
+		// Find the lines that end with the directive
+		allowed := make(map[int]bool)
+		for _, g := range f.Comments {
+			for _, c := range g.List {
+				if isAllow(c) {
+					allowed[pass.Fset.Position(c.Pos()).Line] = true
+				}
+			}
+		}
+		check := func(lits []*ast.BasicLit, text string, starts []int) {
+			checkLiterals(pass, lits, text, starts, allowed)
+		}
+
+		var inspect func(n ast.Node) bool
+		inspect = func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.BinaryExpr:
+				if n.Op != token.ADD {
+					return true
+				}
+				// Check the string literals of the whole concatenation
+				// together, and inspect its other operands separately.
+				ops := concatOperands(n, nil)
+				check(joinLiterals(ops))
+				for _, op := range ops {
+					if _, ok := op.(*ast.BasicLit); !ok {
+						ast.Inspect(op, inspect)
+					}
+				}
+				return false
+
+			case *ast.CallExpr:
+				// Check the string literals among the arguments together,
+				// as in a call writing "[", s, and "]" in turn.
+				var ops []ast.Expr
+				for _, arg := range n.Args {
+					ops = concatOperands(arg, ops)
+				}
+				check(joinLiterals(ops))
+				ast.Inspect(n.Fun, inspect)
+				for _, op := range ops {
+					if _, ok := op.(*ast.BasicLit); !ok {
+						ast.Inspect(op, inspect)
+					}
+				}
+				return false
+
+			case *ast.BasicLit:
+				check(joinLiterals([]ast.Expr{n}))
+			}
+			return true
+		}
+		ast.Inspect(f, inspect)
+
+		for _, g := range f.Comments {
+			if !slices.ContainsFunc(g.List, isAllow) {
+				checkComments(pass, g)
+			}
+		}
+	}
+	return nil, nil
+}
+
+// Return true if comment c is the directive exempting its line.
+func isAllow(c *ast.Comment) bool {
+	return c.Text == allowDirective ||
+		strings.HasPrefix(c.Text, allowDirective+" ")
+}
+
+// Append to ops the operands of the concatenation x, in order.
+func concatOperands(x ast.Expr, ops []ast.Expr) []ast.Expr {
+	if b, ok := x.(*ast.BinaryExpr); ok && b.Op == token.ADD {
+		ops = concatOperands(b.X, ops)
+		return concatOperands(b.Y, ops)
+	}
+	return append(ops, x)
+}
+
+// Join the source text of the string literals among operands ops,
+// with a space standing in for each other operand,
+// and return the literals and the offset of each in the joined text.
+func joinLiterals(ops []ast.Expr) (
+	lits []*ast.BasicLit, text string, starts []int) {
+
+	var b strings.Builder
+	for _, op := range ops {
+		if lit, ok := op.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+			lits = append(lits, lit)
+			starts = append(starts, b.Len())
+			b.WriteString(lit.Value)
+		} else {
+			b.WriteByte(' ')
+		}
+	}
+	return lits, b.String(), starts
+}
+
+// Report the string literals lits containing unmatched matchers
+// in their joined text, which starts each literal at the offset in starts,
+// unless the directive allows the literal's line.
+func checkLiterals(pass *analysis.Pass, lits []*ast.BasicLit, text string,
+	starts []int, allowed map[int]bool) {
+
+	offs := unmatchedOffsets(text)
+	for i, lit := range lits {
+		var own matchertext.OffsetSlice
+		for _, ofs := range offs {
+			if ofs >= int64(starts[i]) &&
+				ofs < int64(starts[i]+len(lit.Value)) {
+				own = append(own, ofs-int64(starts[i]))
+			}
+		}
+		if len(own) > 0 && !allowed[pass.Fset.Position(lit.End()).Line] {
+			checkLiteral(pass, lit, own)
+		}
+	}
+}
+
+// Return the sorted offsets of the unmatched matchers in src.
+func unmatchedOffsets(src string) matchertext.OffsetSlice {
+	// Reading from memory cannot fail
+	offs, _ := matchertext.UnmatchedOffsets(strings.NewReader(src))
+	offs.Sort()
+	return offs
+}
+
+// Report a string literal containing the unmatched matchers
+// at offsets offs in its source text.
+func checkLiteral(pass *analysis.Pass, lit *ast.BasicLit,
+	offs matchertext.OffsetSlice) {
+
+	var edits []analysis.TextEdit
+	if lit.Value[0] == '`' {
+		edits = []analysis.TextEdit{{
+			Pos:     lit.Pos(),
+			End:     lit.End(),
+			NewText: []byte(quoteRaw(lit.Value, offs)),
+		}}
+	} else {
+		edits = escapeEdits(lit.Pos(), lit.Value, offs, matchertext.CEscapes)
+	}
+
+	pass.Report(analysis.Diagnostic{
+		Pos:     lit.Pos(),
+		End:     lit.End(),
+		Message: "string literal contains " + describe(lit.Value, offs),
+		SuggestedFixes: []analysis.SuggestedFix{{
+			Message:   "Escape unmatched matchers",
+			TextEdits: edits,
+		}},
+	})
+}
+
+// Report a comment group containing unmatched matchers.
+func checkComments(pass *analysis.Pass, g *ast.CommentGroup) {
+	// Join the comments with newlines so that pairs may span them,
+	// recording where each comment starts in the joined text.
+	var text strings.Builder
+	starts := make([]int, len(g.List))
+	for i, c := range g.List {
+		starts[i] = text.Len()
+		text.WriteString(c.Text)
+		text.WriteByte('\n')
+	}
+	src := text.String()
+	offs := unmatchedOffsets(src)
+	if len(offs) == 0 {
+		return
+	}
+
+	esc := matchertext.CEscapes
+	if pairEscapes {
+		esc = matchertext.CPairEscapes
+	}
+	var edits []analysis.TextEdit
+	for i, c := range g.List {
+		end := len(src)
+		if i+1 < len(starts) {
+			end = starts[i+1]
+		}
+		var own matchertext.OffsetSlice
+		for _, ofs := range offs {
+			if ofs >= int64(starts[i]) && ofs < int64(end) {
+				own = append(own, ofs-int64(starts[i]))
+			}
+		}
+		edits = append(edits, escapeEdits(c.Pos(), c.Text, own, esc)...)
+	}
+
+	pass.Report(analysis.Diagnostic{
+		Pos:     g.Pos(),
+		End:     g.End(),
+		Message: "comment contains " + describe(src, offs),
+		SuggestedFixes: []analysis.SuggestedFix{{
+			Message:   "Escape unmatched matchers",
+			TextEdits: edits,
+		}},
+	})
+}
+
+// Describe the unmatched matchers at offsets offs in src.
+func describe(src string, offs matchertext.OffsetSlice) string {
+	var b strings.Builder
+	for i, ofs := range offs {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteByte(src[ofs])
+	}
+	if len(offs) == 1 {
+		return "unmatched matcher " + b.String()
+	}
+	return "unmatched matchers " + b.String()
+}
+
+// Return edits replacing the matchers at offsets offs in src,
+// which starts at position pos, with their escapes in esc.
+func escapeEdits(pos token.Pos, src string, offs matchertext.OffsetSlice,
+	esc matchertext.Escapes) []analysis.TextEdit {
+
+	edits := make([]analysis.TextEdit, len(offs))
+	for i, ofs := range offs {
+		p := pos + token.Pos(ofs)
+		edits[i] = analysis.TextEdit{
+			Pos:     p,
+			End:     p + 1,
+			NewText: []byte(esc[src[ofs]]),
+		}
+	}
+	return edits
+}
+
+// Rewrite raw string literal raw as an equivalent interpreted string literal,
+// escaping the unmatched matchers at offsets offs in raw.
+func quoteRaw(raw string, offs matchertext.OffsetSlice) string {
+	var b bytes.Buffer
+	b.WriteByte('"')
+	quote := func(s string) {
+		// Carriage returns are discarded from raw string values
+		q := strconv.Quote(strings.ReplaceAll(s, "\r", ""))
+		b.WriteString(q[1 : len(q)-1])
+	}
+	start := 1 // just past the opening backquote
+	for _, ofs := range offs {
+		quote(raw[start:ofs])
+		b.WriteString(matchertext.CEscapes[raw[ofs]])
+		start = int(ofs) + 1
+	}
+	quote(raw[start : len(raw)-1])
+	b.WriteByte('"')
+	return b.String()
+}
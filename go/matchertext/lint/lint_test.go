@@ -0,0 +1,70 @@
+package lint
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/analysistest"
+)
+
+func TestAnalyzer(t *testing.T) {
+	analysistest.RunWithSuggestedFixes(t, analysistest.TestData(),
+		Analyzer, "a")
+}
+
+// The repository's own Go code must follow the matchertext discipline,
+// with these exemptions:
+//
+//   - _test.go files, whose inputs and expected outputs
+//     deliberately contain unmatched matchers;
+//   - testdata directories, which hold such inputs as fixtures,
+//     including the violations this Analyzer's own tests expect;
+//   - generated files, which the Analyzer always skips.
+//
+// The Analyzer inspects only syntax, so TestRepo parses each file
+// itself rather than loading and type-checking whole packages.
+func TestRepo(t *testing.T) {
+	root := filepath.Join("..", "..") // the go directory of the repository
+	fset := token.NewFileSet()
+	var files []*ast.File
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry,
+		err error) error {
+
+		switch {
+		case err != nil:
+			return err
+		case d.IsDir() && d.Name() == "testdata":
+			return filepath.SkipDir
+		case d.IsDir() || !strings.HasSuffix(path, ".go") ||
+			strings.HasSuffix(path, "_test.go"):
+			return nil
+		}
+		f, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+		files = append(files, f)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pass := &analysis.Pass{
+		Analyzer: Analyzer,
+		Fset:     fset,
+		Files:    files,
+		Report: func(d analysis.Diagnostic) {
+			t.Errorf("%v: %v", fset.Position(d.Pos), d.Message)
+		},
+	}
+	if _, err := Analyzer.Run(pass); err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Errorf("found no files to check")
+	}
+}
@@ -0,0 +1,30 @@
+package a
+
+// Matched pairs (like these) are fine,
+// even when they span
+// (several lines of) a comment group.
+func ok(s string) []string {
+	if s[0] == '[' {
+		return []string{"[" + s + "]", "(" + s + "[" + s + "])"}
+	}
+	print("{", s, "(", s+"[", "])}")
+	return []string{"f(x)", `[a]`, "{}", "no matchers"}
+}
+
+// Unmatched matchers are fine where the directive allows them,
+// as in this comment ending in ].
+//
+//matchertext:allow
+func allowed() string {
+	return "]" //matchertext:allow
+}
+
+// scan for a list ending in ] // want `comment contains unmatched matcher \x5D`
+func scan(next byte) bool {
+	_ = "a\\("                          // want `string literal contains unmatched matcher \x28`
+	_ = "}{"                            // want `string literal contains unmatched matchers \x7D \x7B`
+	_ = `a)\b(`                         // want `string literal contains unmatched matchers \x29 \x28`
+	_ = "x" + string(next) + "]"        // want `string literal contains unmatched matcher \x5D`
+	_ = "(" + string([]byte("[")) + ")" // want `string literal contains unmatched matcher \x5B`
+	return "(]" == ""                   // want `string literal contains unmatched matchers \x28 \x5D`
+}
@@ -2,6 +2,8 @@ package matchertext
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 )
@@ -23,14 +25,23 @@ type Handler interface {
 
 // Fast and simple streaming matchertext parser.
 type Parser struct {
-	r io.ByteReader
+	r  io.ByteReader
+	br *bufio.Reader // r if it is buffered, for fast-path access
 
 	b    int // byte from ungetc witing to be re-getc'd
 	last int // last byte we read from r
 
 	ofs  int64 // byte offset in source starting from 0
 	line int   // line number starting from 1
-	col  int   // column number starting from 1 (counting bytes)
+	col  int   // column number starting from 1 (counting Columns)
+
+	u utf8State // UTF-8 sequence in progress for alphabet checking
+
+	check int64 // offset at which to check limits and context next
+	depth int   // nesting depth of pairs ReadPair is parsing
+	pairs int64 // number of pairs ReadPair has parsed
+
+	onError func(error) error // Tokens' view of each syntax error
 
 	// If HandleError is non-nil,
 	// then the parser invokes it on encountering any syntax error
@@ -38,6 +49,38 @@ type Parser struct {
 	// HandleError may return the same or a different error
 	// to stop parsing, or may return nil to continue despite the error.
 	HandleError func(err error) error
+
+	// Config determines the sensitive matcher pairs
+	// and the alphabet of bytes the parser accepts.
+	// If Config is nil, the parser uses the Standard configuration.
+	// The parser reports bytes outside the alphabet as syntax errors.
+	Config *Config
+
+	// Policy optionally restricts the alphabet further,
+	// for example to reject control codes or invalid UTF-8.
+	// The parser reports violations as syntax errors
+	// at the offset of the offending byte or UTF-8 sequence.
+	// If HandleError accepts a violation,
+	// the parser handles the offending byte normally.
+	Policy Policy
+
+	// Columns selects the unit in which Position and syntax errors
+	// count column numbers.
+	// The zero value ByteColumns counts bytes.
+	Columns ColumnUnit
+
+	// TabWidth is the distance between tab stops for TabColumns.
+	// If TabWidth is zero, the parser uses DefaultTabWidth.
+	TabWidth int
+
+	// Limits optionally bounds the nesting depth, size,
+	// and number of pairs of the input the parser accepts.
+	// The parser stops with a LimitError on exceeding any limit.
+	Limits Limits
+
+	// If Context is non-nil, the parser periodically checks it
+	// and stops with the context's error once it is done.
+	Context context.Context
 }
 
 // NewParser creates and returns a new Parser that reads from stream r.
@@ -57,6 +100,7 @@ func (p *Parser) SetReader(r io.Reader) *Parser {
 
 func (p *Parser) init(r io.ByteReader) *Parser {
 	p.r = r
+	p.br, _ = r.(*bufio.Reader)
 	p.b = -1
 	p.last = -1
 
@@ -64,33 +108,60 @@ func (p *Parser) init(r io.ByteReader) *Parser {
 	p.ofs = 0
 	p.line = 1
 	p.col = 1
+	p.u = utf8State{}
+
+	// reset resource accounting
+	p.check = 0
+	p.depth = 0
+	p.pairs = 0
 
 	return p
 }
 
-// ReadText parses a matchertext stream until it encounters end-of-file (EOF)
+// Return the matchertext configuration the parser is using.
+func (p *Parser) config() *Config {
+	if p.Config == nil {
+		return Standard
+	}
+	return p.Config
+}
+
+// ReadAll parses a matchertext stream until it encounters end-of-file (EOF)
 // or some other error.
 //
-// On encountering any non-matcher byte, ReadText invokes h.Byte to handle it.
+// On encountering any non-matcher byte, ReadAll invokes h.Byte to handle it.
 // The client's Byte handler may return a non-nil error to cease parsing text
 // without consuming the last offered byte.
 //
-// On finding an open matcher (parenthesis, square bracket, or curly brace),
-// Text invokes h.Open to handle the matchertext substring.
-// The Open handler is normally expected to invoke the parser's Pair method
+// On finding an opener of the parser's configuration
+// (by default a parenthesis, square bracket, or curly brace),
+// ReadAll invokes h.Open to handle the matchertext substring.
+// The Open handler is normally expected to invoke the parser's ReadPair method
 // to parse the opener, contents, and matching closer.
 //
+// On finding an unmatched closer, ReadAll reports a syntax error.
+// If the parser's HandleError function accepts the error,
+// ReadAll skips the stray closer and continues parsing.
+//
 // Returns nil on successful parsing until end-of-file (EOF).
 func (p *Parser) ReadAll(h Handler) error {
-	c, e := p.ReadText(h)
-	if e == io.EOF {
-		return nil // successful complete parse
-	}
-	if e == nil {
-		return p.SyntaxError(fmt.Sprintf(
-			"unmatched closer %v", string(byte(c))))
+	for {
+		c, e := p.ReadText(h)
+		if e == io.EOF {
+			return nil // successful complete parse
+		}
+		if e != nil {
+			return e // other error
+		}
+
+		// Report the unmatched closer, which we have not yet consumed
+		e = p.handleError(p.SyntaxErrorKind(UnmatchedCloser, fmt.Sprintf(
+			"unmatched closer %v", string(byte(c)))))
+		if e != nil {
+			return e
+		}
+		p.getc() // skip the stray closer and carry on
 	}
-	return e // other error
 }
 
 // Parse text from a matchertext stream until encountering
@@ -98,30 +169,33 @@ func (p *Parser) ReadAll(h Handler) error {
 // On finding an unmatched closer, returns the closer without consuming it.
 // Returns -1 on EOF or error.
 func (p *Parser) ReadText(h Handler) (closer int, err error) {
+	cfg := p.config()
+	bh, batch := h.(BytesHandler)
 	for {
+		// Handle any buffered run of non-matchers in one batch if we can
+		if batch {
+			if e := p.readBytes(bh); e != nil {
+				return -1, e
+			}
+		}
+
 		// Look ahead one byte in the stream
 		b, e := p.getc()
 		if e != nil {
 			return -1, e
 		}
 
-		switch b {
+		switch cl := cfg.class[b]; {
 
 		// Handle any openers that we encounter.
 		// We expect the handler to invoke Pair
 		// to consume the entire delimited substring.
-		case '(':
-			p.ungetc(b)
-			e = h.Open('(', ')')
-		case '[':
-			p.ungetc(b)
-			e = h.Open('[', ']')
-		case '{':
+		case cl&clOpener != 0:
 			p.ungetc(b)
-			e = h.Open('{', '}')
+			e = h.Open(b, cfg.closer[b])
 
 		// When we see a closer, our current matchertext level is done
-		case ')', ']', '}':
+		case cl&clCloser != 0:
 			p.ungetc(b)
 			return int(b), nil
 
@@ -135,48 +209,67 @@ func (p *Parser) ReadText(h Handler) (closer int, err error) {
 	}
 }
 
-// Pair parses a matching pair of matchers and everything in between.
-// Pair expects to see the specific open matcher o first,
+// ReadPair parses a matching pair of matchers and everything in between.
+// ReadPair expects to see the specific open matcher o first,
 // then it consumes arbitrary text including nested pairs,
 // and finally it consumes the matching closer c.
 // Returns nil if the whole matcher-delimited sequence was parsed successfully,
 // or a non-nil error if anything goes wrong.
+//
+// ReadPair reports any syntax error it finds to the parser's HandleError
+// function, if any, and recovers if HandleError accepts the error.
+// A missing opener is treated as if it were present.
+// An opener left unclosed at end-of-file, or closed by a mismatched closer,
+// is closed implicitly, leaving any mismatched closer unconsumed
+// to be matched against an enclosing opener.
+//
+// ReadPair returns a LimitError if the pair would exceed
+// the parser's limit on nesting depth or number of pairs.
+//
+// Since each Open handler parses a nested pair by calling ReadPair,
+// parsing recurses once per nesting level,
+// and deeply nested input consumes goroutine stack in proportion.
+// Set Limits.MaxDepth to bound the recursion on untrusted input,
+// or use ReadFlat, which does not recurse,
+// to handle input nested arbitrarily deep.
 func (p *Parser) ReadPair(h Handler, o, c byte) error {
 
 	// First consume the opener and make sure it is the expected one.
 	b, e := p.getc()
 	if e == io.EOF || (e == nil && b != o) {
-		return p.SyntaxError(fmt.Sprintf(
-			"expecting opener %v", string(o)))
+		eof := e == io.EOF
+		e = p.handleError(p.SyntaxErrorKind(ExpectedOpener, fmt.Sprintf(
+			"expecting opener %v", string(o))))
+		if e != nil || eof {
+			return e // nothing left to parse at end-of-file
+		}
+		p.ungetc(b) // pretend the opener was present
+	} else if e != nil {
+		return e
 	}
-	if e != nil {
+	ofs, line, col := p.ofs, p.line, p.col // position of the opener
+	defer p.leavePair()
+	if e := p.enterPair(); e != nil {
 		return e
 	}
 
 	// Parse the intervening text delimited by the matcher pair.
-	_, e = p.ReadText(h)
+	cl, e := p.ReadText(h)
 	if e == io.EOF {
-		return p.SyntaxError(fmt.Sprintf(
-			"unmatched opener %v", string(b)))
+		return p.handleError(&SyntaxError{UnmatchedOpener, fmt.Sprintf(
+			"unmatched opener %v", string(o)), ofs, ofs + 1, line, col})
 	}
 	if e != nil {
 		return e
 	}
 
 	// Ensure that the content was closed by the correct matcher.
-	b, e = p.getc()
-	if e == io.EOF {
-		return p.SyntaxError(fmt.Sprintf(
-			"unmatched opener %v", string(b)))
-	}
-	if e != nil {
-		return e
-	}
-	if b != c {
-		return p.SyntaxError(fmt.Sprintf(
+	if byte(cl) != c {
+		return p.handleError(&SyntaxError{MismatchedCloser, fmt.Sprintf(
 			"opener %v closed with mismatched %v",
-			string(o), string(b)))
+			string(o), string(byte(cl))), ofs, p.ofs + 1, line, col})
 	}
+	p.getc() // consume the matching closer
 	return nil
 }
 
@@ -190,34 +283,88 @@ func (p *Parser) getc() (b byte, e error) {
 	}
 
 	// advance our logical position based on last byte read
-	if p.last >= 0 {
-		p.ofs++
-		p.col++
-		if p.last == '\n' {
-			p.line++
-			p.col = 1
-		}
-	}
+	p.step()
 
 	// read the next byte from the input stream
 	b, e = p.r.ReadByte()
 	if e != nil {
+		if e == io.EOF && p.u.need > 0 {
+			if ce := p.checkEOF(); ce != nil {
+				e = ce
+			}
+		}
 		return
 	}
-
 	p.last = int(b)
+
+	// periodically check our limits and context
+	if p.ofs >= p.check {
+		if e = p.checkLimits(); e != nil {
+			return
+		}
+	}
+
+	// check the byte against the alphabet if it is restricted
+	if p.Policy != 0 || p.config().banned {
+		e = p.checkByte(b)
+	}
 	return
 }
 
+// Advance our logical position past the last byte read, if any.
+func (p *Parser) step() {
+	if p.last >= 0 {
+		p.ofs++
+		if p.Columns == ByteColumns {
+			p.col++
+		} else {
+			p.col = p.Columns.advance(p.col, byte(p.last),
+				tabWidth(p.TabWidth))
+		}
+		if p.last == '\n' {
+			p.line++
+			p.col = 1
+		}
+		p.last = -1 // don't advance again at end-of-file
+	}
+}
+
 func (p *Parser) ungetc(b byte) {
 	p.b = int(b)
 }
 
+// Pass a syntax error to the client's HandleError function, if any,
+// returning nil if parsing should continue despite the error.
+func (p *Parser) handleError(err error) error {
+	if p.onError != nil {
+		if e := p.onError(err); e != nil {
+			return e
+		}
+	}
+	if p.HandleError != nil {
+		return p.HandleError(err)
+	}
+	return err
+}
+
 // Create an object describing a syntax error while parsing matchertext.
 // The matchertext parser only creates errors due to unmatched matchers,
 // but clients can use this method to report language-specific syntax errors.
+// The error has no ErrorKind; see SyntaxErrorKind.
 func (p *Parser) SyntaxError(msg string) *SyntaxError {
-	return &SyntaxError{msg, p.ofs, p.line, p.col}
+	return p.SyntaxErrorKind("", msg)
+}
+
+// SyntaxErrorKind creates an object describing a syntax error of kind kind,
+// located at the byte the parser most recently read.
+// Clients can use this method to report language-specific syntax errors
+// with their own error kinds.
+func (p *Parser) SyntaxErrorKind(kind ErrorKind, msg string) *SyntaxError {
+	end := p.ofs
+	if p.last >= 0 {
+		end++ // span the byte most recently read
+	}
+	return &SyntaxError{kind, msg, p.ofs, end, p.line, p.col}
 }
 
 // ReadByte reads and returns the next byte from the matchertext stream,
@@ -228,9 +375,18 @@ func (p *Parser) ReadByte() (b byte, err error) {
 
 // PeekByte returns the next byte from the underlying matchertext stream,
 // without consuming it.
+// If the byte is outside the parser's alphabet,
+// PeekByte returns the byte with the resulting error
+// but still leaves the byte unconsumed,
+// so that the next ReadByte returns it without reporting the error again.
+// A LimitError or Context error instead consumes the byte,
+// and the next ReadByte reports the error again.
 func (p *Parser) PeekByte() (b byte, err error) {
 	b, err = p.getc()
-	if err == nil {
+	var se *SyntaxError
+	if err == nil || (p.last >= 0 && errors.As(err, &se)) {
+		// We read a byte, even if the alphabet check rejected it,
+		// but a limit or context error must recur on the next read.
 		p.ungetc(b)
 	}
 	return
@@ -252,29 +408,8 @@ func (p *Parser) Offset() int64 {
 }
 
 // Position returns the current line and column number
-// within the matchertext being parsed.
+// within the matchertext being parsed,
+// with the column counted in the unit the parser's Columns field selects.
 func (p *Parser) Position() (line int, col int) {
 	return p.line, p.col
 }
-
-// SyntaxError describes any syntax error the parser encounters.
-type SyntaxError struct {
-	msg       string
-	ofs       int64
-	line, col int
-}
-
-// Error returns a human-readable description of the error.
-func (e *SyntaxError) Error() string {
-	return fmt.Sprintf("%v:%v %v", e.line, e.col, e.msg)
-}
-
-// Offset returns the byte position at which the error occurred.
-func (e *SyntaxError) Offset() int64 {
-	return e.ofs
-}
-
-// Position returns the line and column number at which the error occurred.
-func (e *SyntaxError) Position() (line int, column int) {
-	return e.line, e.col
-}
@@ -0,0 +1,125 @@
+package matchertext
+
+import (
+	"strings"
+	"testing"
+)
+
+// testHandler records the non-matcher bytes and pairs it encounters,
+// recursively parsing each pair's content.
+type testHandler struct {
+	p  *Parser
+	sb strings.Builder
+}
+
+func (h *testHandler) Byte(b byte) error {
+	return h.sb.WriteByte(b)
+}
+
+func (h *testHandler) Open(o, c byte) error {
+	h.sb.WriteByte('<')
+	if err := h.p.ReadPair(h, o, c); err != nil {
+		return err
+	}
+	return h.sb.WriteByte('>')
+}
+
+type parseTest struct {
+	cfg *Config
+	in  string
+	out string // expected output, empty if a syntax error is expected
+}
+
+var parseTests = []parseTest{
+	{nil, "a(b[c]d)e", "a<b<c>d>e"},
+	{nil, "a(b", ""},
+	{nil, "a)b", ""},
+	{nil, "a(]b", ""},
+	{Braces, "[0,1) {x}", "[0,1) <x>"},
+	{Braces, "{)}", "<)>"},
+	{Braces, "{", ""},
+	{NewConfig("<>"), "a<b>(c", "a<b>(c"},
+	{Graphic, "a(b)c", "a<b>c"},
+	{Graphic, "a b", ""},
+	{Graphic, "a\x7Fb", ""},
+}
+
+func TestParserConfig(t *testing.T) {
+	for i, pt := range parseTests {
+		h := &testHandler{}
+		h.p = NewParser(strings.NewReader(pt.in))
+		h.p.Config = pt.cfg
+		err := h.p.ReadAll(h)
+		switch {
+		case err != nil && pt.out != "":
+			t.Errorf("%v %q: %v", i, pt.in, err.Error())
+		case err == nil && pt.out == "":
+			t.Errorf("%v %q: expected error", i, pt.in)
+		case err == nil && h.sb.String() != pt.out:
+			t.Errorf("%v %q: expected %q got %q",
+				i, pt.in, pt.out, h.sb.String())
+		}
+		if _, ok := err.(*SyntaxError); err != nil && !ok {
+			t.Errorf("%v %q: unexpected error type %T", i, pt.in, err)
+		}
+	}
+}
+
+func TestParserRecovery(t *testing.T) {
+	for i, ut := range unmatchedTests {
+		var os OffsetSlice
+		h := &testHandler{}
+		h.p = NewParser(strings.NewReader(ut.s))
+		h.p.HandleError = func(err error) error {
+			os = append(os, err.(*SyntaxError).Offset())
+			return nil
+		}
+		if err := h.p.ReadAll(h); err != nil {
+			t.Errorf("%v %q: %v", i, ut.s, err.Error())
+		}
+
+		// Each diagnostic must identify one unmatched matcher
+		os.Sort()
+		if !eqOffsetSlice(os, ut.os) {
+			t.Errorf("%v %q: expected %v got %v", i, ut.s, ut.os, os)
+		}
+	}
+}
+
+func TestParserRecoveryOutput(t *testing.T) {
+	tests := []struct{ in, out string }{
+		{"a)b", "ab"},
+		{"a(b", "a<b>"},
+		{"a[b(c]d", "a<b<c>>d"},
+		{"(a]b)", "<a>b"},
+		{"([)]", "<<>>"},
+	}
+	for i, pt := range tests {
+		n := 0
+		h := &testHandler{}
+		h.p = NewParser(strings.NewReader(pt.in))
+		h.p.HandleError = func(err error) error {
+			n++
+			return nil
+		}
+		if err := h.p.ReadAll(h); err != nil {
+			t.Errorf("%v %q: %v", i, pt.in, err.Error())
+		}
+		if n == 0 || h.sb.String() != pt.out {
+			t.Errorf("%v %q: %v errors, output %q",
+				i, pt.in, n, h.sb.String())
+		}
+	}
+}
+
+func TestParserStop(t *testing.T) {
+	n := 0
+	p := NewParser(strings.NewReader("a)b)c"))
+	p.HandleError = func(err error) error {
+		n++
+		return err
+	}
+	if err := p.ReadAll(&testHandler{p: p}); err == nil || n != 1 {
+		t.Errorf("expected parsing to stop at first error, got %v", err)
+	}
+}
@@ -0,0 +1,144 @@
+package matchertext
+
+import (
+	"sort"
+)
+
+// ColumnUnit selects the unit in which column numbers count.
+// Line and column numbers both start from 1,
+// and a newline byte always starts a new line.
+//
+// The units other than ByteColumns assume UTF-8 text.
+// Each byte that is not a UTF-8 continuation byte starts a new character,
+// so each byte of an ill-formed sequence
+// that is not a continuation byte counts as one character.
+type ColumnUnit int
+
+const (
+	ByteColumns  ColumnUnit = iota // Count bytes
+	RuneColumns                    // Count Unicode characters (runes)
+	UTF16Columns                   // Count UTF-16 code units, as in LSP
+	TabColumns                     // Count runes, expanding tabs to tab stops
+)
+
+// DefaultTabWidth is the distance between tab stops
+// when counting TabColumns, if not otherwise specified.
+const DefaultTabWidth = 8
+
+// Return the column following byte b at column col, counting in unit u,
+// with tab stops every tab columns.
+// Does not handle newlines, which reset the column to 1.
+func (u ColumnUnit) advance(col int, b byte, tab int) int {
+	if u == ByteColumns {
+		return col + 1
+	}
+	if b&0xC0 == 0x80 {
+		return col // UTF-8 continuation byte
+	}
+	switch {
+	case u == UTF16Columns && b >= 0xF0 && b < 0xF5:
+		return col + 2 // rune outside the BMP needs a surrogate pair
+	case u == TabColumns && b == '\t':
+		return col + tab - (col-1)%tab
+	}
+	return col + 1
+}
+
+// Return the tab width to use given a configured width of tab.
+func tabWidth(tab int) int {
+	if tab <= 0 {
+		return DefaultTabWidth
+	}
+	return tab
+}
+
+// LineIndex converts between byte offsets in a source text
+// and line and column numbers in any ColumnUnit.
+// A LineIndex is typically built after parsing a source,
+// for example to convert the positions of syntax errors
+// into the units an editor or terminal expects.
+type LineIndex struct {
+	src    []byte // the indexed source
+	starts []int  // byte offset of the start of each line
+
+	// TabWidth is the distance between tab stops for TabColumns.
+	// If TabWidth is zero, the index uses DefaultTabWidth.
+	TabWidth int
+}
+
+// NewLineIndex builds a LineIndex of source text src.
+// The LineIndex retains src, which the caller must not subsequently modify.
+func NewLineIndex(src []byte) *LineIndex {
+	x := &LineIndex{src: src, starts: []int{0}}
+	for i, b := range src {
+		if b == '\n' {
+			x.starts = append(x.starts, i+1)
+		}
+	}
+	return x
+}
+
+// Lines returns the number of lines in the source,
+// counting a final line that is not terminated by a newline,
+// even if it is empty.
+func (x *LineIndex) Lines() int {
+	return len(x.starts)
+}
+
+// Position returns the line and column at byte offset ofs,
+// with the column counted in unit u.
+// An offset within a multibyte character counts
+// as the position immediately after that character.
+// Position panics if ofs is not within the source or at its end.
+func (x *LineIndex) Position(ofs int, u ColumnUnit) (line, col int) {
+	if ofs < 0 || ofs > len(x.src) {
+		panic("matchertext: offset out of range")
+	}
+	line = sort.Search(len(x.starts), func(i int) bool {
+		return x.starts[i] > ofs
+	})
+	start := x.starts[line-1]
+	if u == ByteColumns {
+		return line, 1 + ofs - start
+	}
+	col, tab := 1, tabWidth(x.TabWidth)
+	for _, b := range x.src[start:ofs] {
+		col = u.advance(col, b, tab)
+	}
+	return line, col
+}
+
+// Offset returns the byte offset at the given line and column,
+// with the column counted in unit u.
+// A column beyond the end of the line yields the offset of the line's end,
+// and a column within a character or tab yields the offset of that character.
+// Offset returns -1 if the line is not within the source.
+func (x *LineIndex) Offset(line, col int, u ColumnUnit) int {
+	if line < 1 || line > len(x.starts) {
+		return -1
+	}
+	start, end := x.starts[line-1], len(x.src)
+	if line < len(x.starts) {
+		end = x.starts[line] - 1 // offset of the newline
+	}
+	if u == ByteColumns {
+		return min(start+max(col, 1)-1, end)
+	}
+
+	// Walk the line until the next character would pass col
+	c, tab := 1, tabWidth(x.TabWidth)
+	ofs := start
+	for ofs < end {
+		next := ofs + 1
+		nc := u.advance(c, x.src[ofs], tab)
+		for next < end && x.src[next]&0xC0 == 0x80 {
+			nc = u.advance(nc, x.src[next], tab)
+			next++
+		}
+		if nc > col {
+			break
+		}
+		c, ofs = nc, next
+	}
+	return ofs
+}
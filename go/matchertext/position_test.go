@@ -0,0 +1,139 @@
+package matchertext
+
+import (
+	"bufio"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestLineIndex(t *testing.T) {
+	// Line 2 contains a tab, a 2-byte rune, and a rune outside the BMP
+	x := NewLineIndex([]byte("ab\n\txé\U0001F600y\n"))
+	if x.Lines() != 3 {
+		t.Errorf("expected 3 lines, got %v", x.Lines())
+	}
+	tests := []struct {
+		ofs       int
+		u         ColumnUnit
+		line, col int
+	}{
+		{0, ByteColumns, 1, 1},
+		{2, RuneColumns, 1, 3},
+		{3, ByteColumns, 2, 1},
+		{4, TabColumns, 2, 9},
+		{5, ByteColumns, 2, 3},
+		{7, ByteColumns, 2, 5},
+		{7, RuneColumns, 2, 4},
+		{7, UTF16Columns, 2, 4},
+		{7, TabColumns, 2, 11},
+		{11, RuneColumns, 2, 5},
+		{11, UTF16Columns, 2, 6},
+		{11, TabColumns, 2, 12},
+		{13, TabColumns, 3, 1},
+	}
+	for i, lt := range tests {
+		line, col := x.Position(lt.ofs, lt.u)
+		if line != lt.line || col != lt.col {
+			t.Errorf("%v position %v:%v, expected %v:%v",
+				i, line, col, lt.line, lt.col)
+		}
+		if ofs := x.Offset(lt.line, lt.col, lt.u); ofs != lt.ofs {
+			t.Errorf("%v offset %v, expected %v", i, ofs, lt.ofs)
+		}
+	}
+
+	// Columns within a tab or past the end of a line,
+	// and lines outside the source
+	if ofs := x.Offset(2, 5, TabColumns); ofs != 3 {
+		t.Errorf("offset within tab %v", ofs)
+	}
+	if ofs := x.Offset(1, 10, RuneColumns); ofs != 2 {
+		t.Errorf("offset past end of line %v", ofs)
+	}
+	if x.Offset(0, 1, ByteColumns) != -1 || x.Offset(4, 1, ByteColumns) != -1 {
+		t.Errorf("offset of line out of range")
+	}
+
+	x.TabWidth = 4
+	if _, col := x.Position(4, TabColumns); col != 5 {
+		t.Errorf("tab width 4 column %v", col)
+	}
+}
+
+// positionHandler checks the parser's position against a LineIndex.
+type positionHandler struct {
+	t *testing.T
+	p *Parser
+	x *LineIndex
+}
+
+func (h *positionHandler) check() {
+	line, col := h.p.Position()
+	xl, xc := h.x.Position(int(h.p.Offset()), h.p.Columns)
+	if line != xl || col != xc {
+		h.t.Errorf("offset %v unit %v: parser %v:%v index %v:%v",
+			h.p.Offset(), h.p.Columns, line, col, xl, xc)
+	}
+}
+
+func (h *positionHandler) Byte(b byte) error {
+	h.check()
+	return nil
+}
+
+func (h *positionHandler) Bytes(b []byte) error {
+	h.check()
+	return nil
+}
+
+func (h *positionHandler) Open(o, c byte) error {
+	h.check()
+	return h.p.ReadPair(h, o, c)
+}
+
+// The parser must count columns exactly as LineIndex does,
+// both byte by byte and in runs.
+func TestParserColumns(t *testing.T) {
+	rng := rand.New(rand.NewSource(11))
+	alpha := []string{"a", "\t", "\n", "()", "é", "世", "\U0001F600"}
+	for i := 0; i < 300; i++ {
+		var sb strings.Builder
+		for j := rng.Intn(40); j > 0; j-- {
+			sb.WriteString(alpha[rng.Intn(len(alpha))])
+		}
+		s := sb.String()
+		x := NewLineIndex([]byte(s))
+		x.TabWidth = 4
+
+		for u := ByteColumns; u <= TabColumns; u++ {
+			// A strings.Reader is read byte by byte,
+			// whereas a bufio.Reader enables the fast path.
+			p := NewParser(strings.NewReader(s))
+			if i%2 == 0 {
+				p = NewParser(bufio.NewReaderSize(
+					strings.NewReader(s), 16))
+			}
+			p.Columns, p.TabWidth = u, 4
+			if err := p.ReadAll(&positionHandler{t, p, x}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestSyntaxErrorColumns(t *testing.T) {
+	for u, want := range []int{4, 3, 3, 10} {
+		p := NewParser(strings.NewReader("\té("))
+		p.Columns = ColumnUnit(u)
+		err := p.ReadAll(&testHandler{p: p})
+		se, ok := err.(*SyntaxError)
+		if !ok {
+			t.Fatalf("expected syntax error, got %v", err)
+		}
+		if line, col := se.Position(); line != 1 || col != want {
+			t.Errorf("unit %v: error at %v:%v, expected 1:%v",
+				u, line, col, want)
+		}
+	}
+}
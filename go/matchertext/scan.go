@@ -0,0 +1,109 @@
+package matchertext
+
+// SpanKind identifies the kind of syntactic element a Span represents.
+type SpanKind int
+
+const (
+	TextSpan      SpanKind = iota // a run of non-matcher bytes
+	PairSpan                      // a matched pair with its content
+	UnmatchedSpan                 // a single unmatched matcher
+)
+
+// Span identifies a syntactic element of matchertext
+// occupying the byte range src[Start:End] of the scanned source.
+//
+// A PairSpan covers an opener, its content, and its matching closer,
+// so the opener is at src[Start] and the closer at src[End-1].
+// The spans of the pair's content follow the PairSpan itself.
+type Span struct {
+	Kind       SpanKind
+	Start, End int
+}
+
+// Scan scans matchertext in byte slice src
+// according to the Standard configuration,
+// and returns the spans of all text runs, matched pairs,
+// and unmatched matchers it contains.
+// See Config.Scan for details.
+func Scan(src []byte) []Span {
+	return Standard.Scan(src)
+}
+
+// ScanString is like Scan but scans a string.
+func ScanString(src string) []Span {
+	return Standard.ScanString(src)
+}
+
+// Scan scans matchertext in byte slice src according to configuration c,
+// and returns the spans of all text runs, matched pairs,
+// and unmatched matchers it contains.
+//
+// The returned spans are ordered by their Start offsets,
+// which means that each PairSpan precedes the spans of its content.
+// Text runs never contain matchers, even unmatched ones,
+// and the closer of a matched pair appears only as the end of its PairSpan.
+// Scan identifies unmatched matchers exactly as UnmatchedOffsets does.
+// The alphabet of c does not affect the result.
+//
+// Scan does not copy src: the spans merely index into it.
+// Unlike Parser, Scan uses a heap-allocated stack of pending openers
+// rather than recursion, so deep nesting costs no Go stack space.
+func (c *Config) Scan(src []byte) []Span {
+	return scan(c, src)
+}
+
+// ScanString is like Scan but scans a string.
+func (c *Config) ScanString(src string) []Span {
+	return scan(c, src)
+}
+
+func scan[S []byte | string](c *Config, src S) []Span {
+	spans := make([]Span, 0, len(src)/16) // guess to limit reallocation
+	var stack []int                       // indexes of pending openers
+
+	start := 0 // start of the current text run
+	for i := 0; i < len(src); i++ {
+
+		// Skip ahead to the next matcher, extending the current text run
+		i += indexMatcher(c, src[i:])
+		if i == len(src) {
+			break
+		}
+		b := src[i]
+		cl := c.class[b]
+
+		// End the text run leading up to this matcher
+		if start < i {
+			spans = append(spans, Span{TextSpan, start, i})
+		}
+		start = i + 1
+
+		// Openers remain unmatched until we find their closers
+		if cl&clOpener != 0 {
+			stack = append(stack, len(spans))
+			spans = append(spans, Span{UnmatchedSpan, i, i + 1})
+			continue
+		}
+
+		// Openers closed by this closer without matching it
+		// remain unmatched, exactly as in unmatchedScan.
+		matched := false
+		for len(stack) > 0 && !matched {
+			j := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if c.IsMatched(src[spans[j].Start], b) {
+				spans[j] = Span{PairSpan, spans[j].Start, i + 1}
+				matched = true
+			}
+		}
+		if !matched {
+			spans = append(spans, Span{UnmatchedSpan, i, i + 1})
+		}
+	}
+
+	// End any text run at the end of the source
+	if start < len(src) {
+		spans = append(spans, Span{TextSpan, start, len(src)})
+	}
+	return spans
+}
@@ -0,0 +1,177 @@
+package matchertext
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"testing"
+)
+
+type scanTest struct {
+	s     string
+	spans []Span
+}
+
+var scanTests = []scanTest{
+	{"", nil},
+	{"abc", []Span{{TextSpan, 0, 3}}},
+	{"a(b)c", []Span{{TextSpan, 0, 1}, {PairSpan, 1, 4},
+		{TextSpan, 2, 3}, {TextSpan, 4, 5}}},
+	{"[{}]", []Span{{PairSpan, 0, 4}, {PairSpan, 1, 3}}},
+	{"a)b(", []Span{{TextSpan, 0, 1}, {UnmatchedSpan, 1, 2},
+		{TextSpan, 2, 3}, {UnmatchedSpan, 3, 4}}},
+	{"([)", []Span{{PairSpan, 0, 3}, {UnmatchedSpan, 1, 2}}},
+}
+
+func TestScan(t *testing.T) {
+	for i, st := range scanTests {
+		spans := ScanString(st.s)
+		if !eqSpans(spans, st.spans) {
+			t.Errorf("%v %q: expected %v got %v",
+				i, st.s, st.spans, spans)
+		}
+		if !eqSpans(Scan([]byte(st.s)), spans) {
+			t.Errorf("%v %q: Scan and ScanString disagree", i, st.s)
+		}
+	}
+}
+
+func TestScanUnmatched(t *testing.T) {
+	for i, ut := range unmatchedTests {
+		var os OffsetSlice
+		for _, sp := range ScanString(ut.s) {
+			if sp.Kind == UnmatchedSpan {
+				os = append(os, int64(sp.Start))
+			}
+		}
+		if !eqOffsetSlice(os, ut.os) {
+			t.Errorf("%v %q: expected %v got %v", i, ut.s, ut.os, os)
+		}
+	}
+}
+
+func eqSpans(a, b []Span) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Synthesize large matchertext documents for benchmarking:
+// one dense with matchers, and one with only occasional matchers.
+var benchDocs = []struct {
+	name string
+	doc  []byte
+}{
+	{"Dense", bytes.Repeat([]byte("The quick (brown) fox [jumps over "+
+		"{the}] lazy dog, and (runs [away {fast}]) into the woods.\n"),
+		1<<14)},
+	{"Sparse", bytes.Repeat([]byte("Most bytes in real documents are "+
+		"not matchers, as in this long sentence of plain prose text "+
+		"that goes on for quite some time before finally using a "+
+		"parenthetical (like this one) and then ending.\n"), 1<<13)},
+}
+
+// Handler that simply counts bytes and recursively parses pairs.
+type countHandler struct {
+	p *Parser
+	n int
+}
+
+func (h *countHandler) Byte(b byte) error {
+	h.n++
+	return nil
+}
+
+func (h *countHandler) Open(o, c byte) error {
+	return h.p.ReadPair(h, o, c)
+}
+
+// Handler that also accepts runs of non-matchers in batches.
+type countBytesHandler struct {
+	countHandler
+}
+
+func (h *countBytesHandler) Bytes(b []byte) error {
+	h.n += len(b)
+	return nil
+}
+
+func (h *countBytesHandler) Open(o, c byte) error {
+	return h.p.ReadPair(h, o, c)
+}
+
+func BenchmarkScan(b *testing.B) {
+	for _, bd := range benchDocs {
+		b.Run(bd.name, func(b *testing.B) {
+			b.SetBytes(int64(len(bd.doc)))
+			for b.Loop() {
+				Scan(bd.doc)
+			}
+		})
+	}
+}
+
+func BenchmarkParser(b *testing.B) {
+	for _, bd := range benchDocs {
+		b.Run(bd.name, func(b *testing.B) {
+			b.SetBytes(int64(len(bd.doc)))
+			for b.Loop() {
+				r := bufio.NewReader(bytes.NewReader(bd.doc))
+				h := &countHandler{p: NewParser(r)}
+				if err := h.p.ReadAll(h); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkParserBytes(b *testing.B) {
+	for _, bd := range benchDocs {
+		b.Run(bd.name, func(b *testing.B) {
+			b.SetBytes(int64(len(bd.doc)))
+			for b.Loop() {
+				r := bufio.NewReader(bytes.NewReader(bd.doc))
+				h := &countBytesHandler{}
+				h.p = NewParser(r)
+				if err := h.p.ReadAll(h); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkUnmatchedOffsets(b *testing.B) {
+	for _, bd := range benchDocs {
+		b.Run(bd.name, func(b *testing.B) {
+			b.SetBytes(int64(len(bd.doc)))
+			for b.Loop() {
+				var r io.Reader = bytes.NewReader(bd.doc)
+				if _, err := UnmatchedOffsets(r); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkUnmatchedOffsetsBuffered(b *testing.B) {
+	for _, bd := range benchDocs {
+		b.Run(bd.name, func(b *testing.B) {
+			b.SetBytes(int64(len(bd.doc)))
+			for b.Loop() {
+				r := bufio.NewReader(bytes.NewReader(bd.doc))
+				if _, err := UnmatchedOffsets(r); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package matchertext
+
+import (
+	"bufio"
+	"strings"
+)
+
+// ScanRecords returns a split function for a bufio.Scanner
+// that splits its input into records terminated by byte delim,
+// but only at delimiters outside any matched pair
+// of the Standard configuration.
+// See Config.ScanRecords for details.
+func ScanRecords(delim byte) bufio.SplitFunc {
+	return Standard.ScanRecords(delim)
+}
+
+// ScanRecords returns a split function for a bufio.Scanner
+// that splits its input into records terminated by byte delim,
+// but only at delimiters outside any matched pair of configuration c.
+// A record may thus contain delimiters, such as newlines,
+// within embedded matchertext.
+// The returned records exclude the terminating delimiter.
+// The last record need not be terminated by a delimiter,
+// and is returned only if it is non-empty.
+//
+// ScanRecords identifies matched pairs as UnmatchedOffsets does,
+// so an unmatched opener does not prevent splitting at later delimiters.
+// Since whether an opener is matched depends on the text that follows,
+// the scanner may need to buffer input up to the opener's closer
+// before it can decide where a record ends.
+//
+// The returned split function carries its scanning state between calls,
+// so that it examines each byte of the input only once
+// however many times the scanner asks it to split the same data.
+// Each returned SplitFunc must therefore serve a single bufio.Scanner.
+//
+// ScanRecords panics if delim is a matcher of c.
+func (c *Config) ScanRecords(delim byte) bufio.SplitFunc {
+	if c.IsMatcher(delim) {
+		panic("matchertext: record delimiter must not be a matcher")
+	}
+	rs := &recordSplitter{cfg: c, delim: delim}
+	return rs.split
+}
+
+// recordSplitter is the state of a ScanRecords split function.
+// It records offsets in the input stream, not the data,
+// so that consuming a record need not adjust them.
+type recordSplitter struct {
+	cfg   *Config
+	delim byte
+	start int   // offset in the input of the current data
+	next  int   // offset in the input of the next byte to scan
+	stack []int // offsets of pending openers
+	cands []int // delimiters not yet known to be inside a matched pair
+}
+
+func (rs *recordSplitter) split(data []byte, atEOF bool) (
+	int, []byte, error) {
+
+	if d := rs.indexRecord(data, atEOF); d >= 0 {
+		rs.advance(d + 1)
+		return d + 1, data[:d], nil
+	}
+	if atEOF && len(data) > 0 {
+		rs.advance(len(data))
+		return len(data), data, nil
+	}
+	return 0, nil, nil // request more data
+}
+
+// Return the offset of the first delimiter in data
+// that is known to lie outside any matched pair,
+// or -1 if there is no such delimiter or more data is needed to decide.
+// If atEOF is true, data is the entire remaining input.
+// Resumes scanning where the last call left off,
+// since the scanner passes the same data again, extended,
+// until the split function consumes some of it.
+func (rs *recordSplitter) indexRecord(data []byte, atEOF bool) int {
+	c := rs.cfg
+	if rs.next-rs.start > len(data) { // not the data we were scanning
+		rs.stack, rs.cands, rs.next = nil, nil, rs.start
+	}
+
+	// Return the first candidate delimiter no pending opener precedes,
+	// which must therefore lie outside any matched pair.
+	confirmed := func() int {
+		if len(rs.cands) > 0 &&
+			(len(rs.stack) == 0 || rs.stack[0] > rs.cands[0]) {
+			return rs.cands[0] - rs.start
+		}
+		return -1
+	}
+
+	// A record may already be decided by the bytes scanned before
+	if d := confirmed(); d >= 0 {
+		return d
+	}
+
+	for ; rs.next-rs.start < len(data); rs.next++ {
+		b := data[rs.next-rs.start]
+		switch {
+		case b == rs.delim:
+			rs.cands = append(rs.cands, rs.next)
+
+		case c.IsOpener(b):
+			rs.stack = append(rs.stack, rs.next)
+
+		case c.IsCloser(b):
+			// Pop openers until we find one this closer matches,
+			// leaving openers closed without matching unmatched.
+			for len(rs.stack) > 0 {
+				o := rs.stack[len(rs.stack)-1]
+				rs.stack = rs.stack[:len(rs.stack)-1]
+				if c.IsMatched(data[o-rs.start], b) {
+					// Delimiters within the pair are not candidates
+					for len(rs.cands) > 0 && rs.cands[len(rs.cands)-1] > o {
+						rs.cands = rs.cands[:len(rs.cands)-1]
+					}
+					break
+				}
+			}
+
+		default:
+			continue
+		}
+		if d := confirmed(); d >= 0 {
+			rs.next++
+			return d
+		}
+	}
+
+	// At end-of-file, all pending openers are unmatched
+	if atEOF && len(rs.cands) > 0 {
+		return rs.cands[0] - rs.start
+	}
+	return -1
+}
+
+// Consume the first n bytes of the data.
+// The state left for the rest of the data is what scanning it afresh
+// would produce: any pending openers before a confirmed delimiter
+// are unmatched, as are the closers that resolved them,
+// and a pair enclosing the delimiter would have removed it as a candidate.
+func (rs *recordSplitter) advance(n int) {
+	rs.start += n
+	for len(rs.stack) > 0 && rs.stack[0] < rs.start {
+		rs.stack = rs.stack[1:]
+	}
+	for len(rs.cands) > 0 && rs.cands[0] < rs.start {
+		rs.cands = rs.cands[1:]
+	}
+	rs.next = max(rs.next, rs.start)
+}
+
+// Split slices s into all substrings separated by sep
+// outside any matched pair of the Standard configuration,
+// and returns a slice of the substrings between those separators.
+// See Config.Split for details.
+func Split(s, sep string) []string {
+	return Standard.Split(s, sep)
+}
+
+// Split slices s into all substrings separated by sep
+// outside any matched pair of configuration c,
+// and returns a slice of the substrings between those separators.
+// Split identifies matched pairs as UnmatchedOffsets does.
+// Like strings.Split, Split returns a slice of length 1 containing s
+// if s contains no such separators.
+//
+// Split panics if sep is empty or contains any matcher of c.
+func (c *Config) Split(s, sep string) []string {
+	if sep == "" || indexMatcher(c, sep) < len(sep) {
+		panic("matchertext: separator must be non-empty " +
+			"and contain no matchers")
+	}
+
+	// Since sep contains no matchers, any separator lies within a text run,
+	// so we need only search the text runs outside any matched pair.
+	var parts []string
+	start, pairEnd := 0, 0
+	for _, sp := range scan(c, s) {
+		if sp.Start < pairEnd {
+			continue // within a matched pair
+		}
+		switch sp.Kind {
+		case PairSpan:
+			pairEnd = sp.End
+		case TextSpan:
+			for i := sp.Start; ; {
+				j := strings.Index(s[i:sp.End], sep)
+				if j < 0 {
+					break
+				}
+				parts = append(parts, s[start:i+j])
+				i += j + len(sep)
+				start = i
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
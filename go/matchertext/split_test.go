@@ -0,0 +1,160 @@
+package matchertext
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+var recordTests = []struct {
+	in  string
+	out []string
+}{
+	{"", nil},
+	{"a\nb\n", []string{"a", "b"}},
+	{"a\nb", []string{"a", "b"}},
+	{"a\n\nb", []string{"a", "", "b"}},
+	{"k=[x\ny]\nz", []string{"k=[x\ny]", "z"}},
+	{"a(b{\n}\n)c\nd", []string{"a(b{\n}\n)c", "d"}},
+	{"a(\nb\n", []string{"a(", "b"}},             // unmatched opener
+	{"a(\nb]\nc)\n", []string{"a(", "b]", "c)"}}, // mismatched
+	{"a[(\n]\nb", []string{"a[(\n]", "b"}},
+	{"a)\nb", []string{"a)", "b"}},
+}
+
+func TestScanRecords(t *testing.T) {
+	for i, rt := range recordTests {
+		// Feed the scanner tiny chunks to exercise requests for more data
+		for _, n := range []int{1, 3, 100} {
+			sc := bufio.NewScanner(&chunkReader{rt.in, n})
+			sc.Split(ScanRecords('\n'))
+			var out []string
+			for sc.Scan() {
+				out = append(out, sc.Text())
+			}
+			if sc.Err() != nil {
+				t.Errorf("%v: %v", i, sc.Err())
+			}
+			if fmt.Sprintf("%q", out) != fmt.Sprintf("%q", rt.out) {
+				t.Errorf("%v chunk %v: expected %q got %q",
+					i, n, rt.out, out)
+			}
+		}
+	}
+
+	// Records already decided must not wait for more input
+	split := ScanRecords('\n')
+	data := []byte("(\n(\n]")
+	var out []string
+	for {
+		n, rec, err := split(data, false)
+		if err != nil || n == 0 {
+			break
+		}
+		out = append(out, string(rec))
+		data = data[n:]
+	}
+	if fmt.Sprintf("%q", out) != fmt.Sprintf("%q", []string{"(", "("}) {
+		t.Errorf("without EOF: got %q", out)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		in, sep string
+		out     []string
+	}{
+		{"", ",", []string{""}},
+		{"a,b", ",", []string{"a", "b"}},
+		{"f(a,b),[c,d],e", ",", []string{"f(a,b)", "[c,d]", "e"}},
+		{"a(,b", ",", []string{"a(", "b"}},
+		{"a), b, ", ", ", []string{"a)", "b", ""}},
+		{"x::{y::z}::", "::", []string{"x", "{y::z}", ""}},
+	}
+	for i, st := range tests {
+		out := Split(st.in, st.sep)
+		if fmt.Sprintf("%q", out) != fmt.Sprintf("%q", st.out) {
+			t.Errorf("%v: expected %q got %q", i, st.out, out)
+		}
+	}
+	if out := Braces.Split("[0,1),{2,3}", ","); len(out) != 3 {
+		t.Errorf("Braces split into %q", out)
+	}
+}
+
+// ScanRecords and Split must agree with each other, and with an Index.
+func TestSplitRandom(t *testing.T) {
+	rng := rand.New(rand.NewSource(17))
+	for i := 0; i < 1000; i++ {
+		s := randString(rng, "ab;()[]{}", rng.Intn(40))
+
+		// Split at delimiters that no matched pair encloses
+		x := NewIndex([]byte(s))
+		var want []string
+		start := 0
+		for j := 0; j < len(s); j++ {
+			if s[j] == ';' && x.Enclosing(j) < 0 {
+				want = append(want, s[start:j])
+				start = j + 1
+			}
+		}
+		want = append(want, s[start:])
+
+		if out := Split(s, ";"); fmt.Sprint(out) != fmt.Sprint(want) {
+			t.Errorf("%q: Split expected %q got %q", s, want, out)
+		}
+
+		sc := bufio.NewScanner(&chunkReader{s, 1 + rng.Intn(4)})
+		sc.Split(ScanRecords(';'))
+		var recs []string
+		for sc.Scan() {
+			recs = append(recs, sc.Text())
+		}
+		if len(want[len(want)-1]) == 0 {
+			want = want[:len(want)-1] // no empty final record
+		}
+		if fmt.Sprintf("%q", recs) != fmt.Sprintf("%q", want) {
+			t.Errorf("%q: ScanRecords expected %q got %q",
+				s, want, recs)
+		}
+	}
+}
+
+// Scanning records must take linear time even when every record
+// is resolved only at the end of a long run of pending openers.
+func TestScanRecordsLong(t *testing.T) {
+	const n = 1 << 16
+	for _, in := range []string{
+		strings.Repeat("(\n", n),
+		strings.Repeat("(\n", n) + "]",
+		strings.Repeat("a\n", n),
+	} {
+		sc := bufio.NewScanner(strings.NewReader(in))
+		sc.Buffer(nil, len(in)+1)
+		sc.Split(ScanRecords('\n'))
+		recs := 0
+		for sc.Scan() {
+			recs++
+		}
+		if sc.Err() != nil || recs < n {
+			t.Errorf("%q...: got %v records, %v", in[:4], recs, sc.Err())
+		}
+	}
+}
+
+func BenchmarkScanRecords(b *testing.B) {
+	for _, bd := range benchDocs {
+		b.Run(bd.name, func(b *testing.B) {
+			b.SetBytes(int64(len(bd.doc)))
+			for b.Loop() {
+				sc := bufio.NewScanner(bytes.NewReader(bd.doc))
+				sc.Split(ScanRecords('\n'))
+				for sc.Scan() {
+				}
+			}
+		})
+	}
+}
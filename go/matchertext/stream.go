@@ -0,0 +1,188 @@
+package matchertext
+
+import (
+	"bufio"
+	"io"
+	"sort"
+)
+
+// filterSink represents the output of an unmatchedFilter.
+type filterSink interface {
+	text(p []byte) error    // handle bytes containing no unmatched matchers
+	unmatched(b byte) error // handle a single unmatched matcher
+}
+
+// unmatchedFilter identifies the unmatched matchers in a byte stream
+// incrementally, passing the stream to a filterSink in order.
+// The filter holds back input only while some opener remains pending,
+// because until its closer appears, it may yet turn out to be unmatched.
+type unmatchedFilter struct {
+	cfg   *Config
+	out   filterSink
+	held  []byte // input held back since the oldest pending opener
+	stack []int  // positions in held of pending openers
+	um    []int  // positions in held of known unmatched matchers
+}
+
+// Filter the next chunk p of the input stream.
+func (f *unmatchedFilter) write(p []byte) error {
+	for len(p) > 0 {
+
+		// With no openers pending, pass text straight through.
+		if len(f.stack) == 0 {
+			n := indexMatcher(f.cfg, p)
+			if err := f.text(p[:n]); err != nil {
+				return err
+			}
+			if n == len(p) {
+				return nil
+			}
+			b := p[n]
+			p = p[n+1:]
+			if f.cfg.IsCloser(b) {
+				if err := f.out.unmatched(b); err != nil {
+					return err
+				}
+				continue
+			}
+			f.held = append(f.held[:0], b)
+			f.stack = append(f.stack, 0)
+			continue
+		}
+
+		// Otherwise hold back text up through the next matcher
+		n := indexMatcher(f.cfg, p)
+		if n == len(p) {
+			f.held = append(f.held, p...)
+			return nil
+		}
+		pos := len(f.held) + n
+		b := p[n]
+		f.held = append(f.held, p[:n+1]...)
+		p = p[n+1:]
+
+		if f.cfg.IsOpener(b) {
+			f.stack = append(f.stack, pos)
+			continue
+		}
+
+		// Openers closed by this closer without matching it
+		// remain unmatched, exactly as in unmatchedScan.
+		matched := false
+		for len(f.stack) > 0 && !matched {
+			o := f.stack[len(f.stack)-1]
+			f.stack = f.stack[:len(f.stack)-1]
+			if f.cfg.IsMatched(f.held[o], b) {
+				matched = true
+			} else {
+				f.um = append(f.um, o)
+			}
+		}
+		if !matched {
+			f.um = append(f.um, pos)
+		}
+
+		// Release the held input once no openers remain pending
+		if len(f.stack) == 0 {
+			if err := f.flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Finish filtering at the end of the input stream,
+// where any pending openers are unmatched.
+func (f *unmatchedFilter) close() error {
+	f.um = append(f.um, f.stack...)
+	f.stack = f.stack[:0]
+	return f.flush()
+}
+
+// Pass all held-back input to the sink.
+func (f *unmatchedFilter) flush() error {
+	sort.Ints(f.um)
+	l := 0
+	for _, pos := range f.um {
+		if err := f.text(f.held[l:pos]); err != nil {
+			return err
+		}
+		if err := f.out.unmatched(f.held[pos]); err != nil {
+			return err
+		}
+		l = pos + 1
+	}
+	err := f.text(f.held[l:])
+	f.held, f.um = f.held[:0], f.um[:0]
+	return err
+}
+
+// Pass text to the sink unless it is empty.
+func (f *unmatchedFilter) text(p []byte) error {
+	if len(p) == 0 {
+		return nil
+	}
+	return f.out.text(p)
+}
+
+// Filter the entire stream r.
+func (f *unmatchedFilter) readFrom(r io.Reader) error {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if werr := f.write(buf[:n]); werr != nil {
+				return werr
+			}
+		}
+		if err == io.EOF {
+			return f.close()
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// WriteUnmatched reads matchertext from r until end-of-file
+// and writes to w the same byte mask that Unmatched would produce:
+// unmatched matchers are copied, and all other bytes become zero bytes.
+//
+// WriteUnmatched holds back output only while an opener remains pending,
+// so memory use is proportional to the longest such span of the input,
+// which may be the whole input if it starts with an unmatched opener.
+//
+// WriteUnmatched uses the Standard matchertext configuration.
+func WriteUnmatched(w io.Writer, r io.Reader) error {
+	return Standard.WriteUnmatched(w, r)
+}
+
+// WriteUnmatched is like the WriteUnmatched function
+// but identifies the unmatched matchers of configuration c.
+func (c *Config) WriteUnmatched(w io.Writer, r io.Reader) error {
+	bw := bufio.NewWriter(w)
+	f := unmatchedFilter{cfg: c, out: maskSink{bw}}
+	if err := f.readFrom(r); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// maskSink writes the unmatched-matcher byte mask of its input.
+type maskSink struct {
+	w *bufio.Writer
+}
+
+func (s maskSink) text(p []byte) error {
+	for range p {
+		if err := s.w.WriteByte(0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s maskSink) unmatched(b byte) error {
+	return s.w.WriteByte(b)
+}
@@ -1,5 +1,10 @@
 package matchertext
 
+import (
+	"errors"
+	"fmt"
+)
+
 // IsMatcher returns true if b is any ASCII matcher character:
 // parentheses (), square brackets [], or curly braces {}.
 func IsMatcher(b byte) bool {
@@ -24,3 +29,152 @@ func IsMatched(o, c byte) bool {
 		(o == '[' && c == ']') ||
 		(o == '{' && c == '}')
 }
+
+// Config represents a matchertext configuration:
+// the set of sensitive matcher pairs that must match in valid matchertext,
+// together with the alphabet of bytes that may appear in it at all.
+//
+// The standard configuration makes the three ASCII matcher pairs sensitive
+// and allows any byte.
+// A tightening configuration removes bytes from the alphabet
+// and/or adds sensitive pairs,
+// while a loosening configuration adds bytes or removes pairs.
+//
+// A Config is immutable once created and may be shared freely.
+type Config struct {
+	pairs  string     // opener/closer pairs, e.g., "()[]{}"
+	closer [256]byte  // matching closer for each opener, 0 if none
+	class  [256]uint8 // classification bits for each byte
+	banned bool       // true if the alphabet excludes any bytes
+	words  []uint64   // each matcher byte replicated across a word
+}
+
+// Byte classification bits in Config.class
+const (
+	clOpener = 1 << iota // byte is a sensitive opener
+	clCloser             // byte is a sensitive closer
+	clBanned             // byte is outside the alphabet
+
+	clMatcher = clOpener | clCloser
+)
+
+// Standard is the standard matchertext configuration,
+// in which parentheses, square brackets, and curly braces must match
+// and all bytes are allowed.
+var Standard = NewConfig("()[]{}")
+
+// Braces is a loosened configuration in which only curly braces must match,
+// leaving parentheses and square brackets free for unmatched uses
+// such as half-open intervals like [0,1).
+//
+//matchertext:allow
+var Braces = NewConfig("{}")
+
+// Graphic is a tightened configuration suitable for matchertext in URIs.
+// The standard matcher pairs must match,
+// and the ASCII space and control codes are excluded from the alphabet.
+// Bytes outside the ASCII range remain allowed,
+// since whether they encode graphical characters depends on their context.
+var Graphic = Standard.WithAlphabet(isGraphicByte)
+
+// NewConfig creates a configuration in which the matcher pairs
+// listed in string pairs are sensitive and all bytes are allowed.
+// The pairs string must consist of opener/closer byte pairs, such as "()[]{}".
+// NewConfig panics if pairs has odd length, if any byte appears twice,
+// or if any pair uses the zero byte.
+// Use ParseConfig for pairs that come from user input.
+func NewConfig(pairs string) *Config {
+	c, err := ParseConfig(pairs)
+	if err != nil {
+		panic(err.Error())
+	}
+	return c
+}
+
+// ParseConfig creates a configuration as NewConfig does,
+// but returns an error rather than panicking if pairs is invalid.
+func ParseConfig(pairs string) (*Config, error) {
+	if len(pairs)%2 != 0 {
+		return nil, errors.New(
+			"matchertext: pairs must be a string of opener/closer pairs")
+	}
+	c := &Config{pairs: pairs}
+	for i := 0; i < len(pairs); i += 2 {
+		o, cl := pairs[i], pairs[i+1]
+		if o == 0 || cl == 0 || o == cl ||
+			c.class[o] != 0 || c.class[cl] != 0 {
+			return nil, fmt.Errorf("matchertext: invalid matcher pair %q",
+				pairs[i:i+2])
+		}
+		c.class[o] = clOpener
+		c.class[cl] = clCloser
+		c.closer[o] = cl
+		c.words = append(c.words, uint64(o)*lsbs, uint64(cl)*lsbs)
+	}
+	return c, nil
+}
+
+// WithAlphabet returns a copy of configuration c
+// whose alphabet consists of the bytes for which allow returns true.
+// The sensitive matcher pairs are unchanged.
+// WithAlphabet panics if allow excludes any of those matchers,
+// since the resulting configuration could never parse its own pairs.
+func (c *Config) WithAlphabet(allow func(b byte) bool) *Config {
+	nc := *c
+	nc.banned = false
+	for i := range nc.class {
+		nc.class[i] &^= clBanned
+		if !allow(byte(i)) {
+			if nc.class[i]&clMatcher != 0 {
+				panic("matchertext: alphabet excludes matcher " +
+					string(byte(i)))
+			}
+			nc.class[i] |= clBanned
+			nc.banned = true
+		}
+	}
+	return &nc
+}
+
+// Pairs returns the sensitive matcher pairs of c as a string
+// of opener/closer byte pairs, in the same form NewConfig accepts.
+func (c *Config) Pairs() string {
+	return c.pairs
+}
+
+// IsMatcher returns true if b is an opener or closer in configuration c.
+func (c *Config) IsMatcher(b byte) bool {
+	return c.class[b]&clMatcher != 0
+}
+
+// IsOpener returns true if b is an opener in configuration c.
+func (c *Config) IsOpener(b byte) bool {
+	return c.class[b]&clOpener != 0
+}
+
+// IsCloser returns true if b is a closer in configuration c.
+func (c *Config) IsCloser(b byte) bool {
+	return c.class[b]&clCloser != 0
+}
+
+// IsMatched returns true if o is an opener in configuration c
+// and b is its matching closer.
+func (c *Config) IsMatched(o, b byte) bool {
+	return c.closer[o] != 0 && c.closer[o] == b
+}
+
+// Closer returns the closer matching opener o in configuration c,
+// or zero if o is not an opener.
+func (c *Config) Closer(o byte) byte {
+	return c.closer[o]
+}
+
+// Allows returns true if byte b is in the alphabet of configuration c.
+func (c *Config) Allows(b byte) bool {
+	return c.class[b]&clBanned == 0
+}
+
+// Return true if b is an ASCII graphical character or a non-ASCII byte.
+func isGraphicByte(b byte) bool {
+	return b > ' ' && b != 0x7F
+}
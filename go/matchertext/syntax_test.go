@@ -0,0 +1,74 @@
+package matchertext
+
+import (
+	"testing"
+)
+
+func TestStandardConfig(t *testing.T) {
+	for i := 0; i < 256; i++ {
+		b := byte(i)
+		if Standard.IsOpener(b) != IsOpener(b) ||
+			Standard.IsCloser(b) != IsCloser(b) ||
+			Standard.IsMatcher(b) != IsMatcher(b) {
+			t.Errorf("Standard disagrees on byte %#02x", b)
+		}
+		if !Standard.Allows(b) {
+			t.Errorf("Standard disallows byte %#02x", b)
+		}
+		for j := 0; j < 256; j++ {
+			c := byte(j)
+			if Standard.IsMatched(b, c) != IsMatched(b, c) {
+				t.Errorf("Standard disagrees on pair %q", []byte{b, c})
+			}
+		}
+	}
+}
+
+func TestConfig(t *testing.T) {
+	c := NewConfig("<>{}")
+	if !c.IsOpener('<') || !c.IsCloser('>') || !c.IsMatched('<', '>') {
+		t.Errorf("angle brackets not configured as a pair")
+	}
+	if c.IsMatcher('(') || c.IsMatched('{', '>') {
+		t.Errorf("unexpected matchers in %q", c.Pairs())
+	}
+	if c.Closer('{') != '}' || c.Closer('}') != 0 {
+		t.Errorf("wrong closers")
+	}
+
+	g := c.WithAlphabet(isGraphicByte)
+	if g.Allows(' ') || g.Allows('\n') || !g.Allows('x') || !g.Allows(0x80) {
+		t.Errorf("wrong alphabet")
+	}
+	if g.Pairs() != c.Pairs() || !c.Allows(' ') {
+		t.Errorf("WithAlphabet altered original configuration")
+	}
+}
+
+func TestBadConfig(t *testing.T) {
+	for _, pairs := range []string{"(", "(())", "((", "\x00)"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("NewConfig(%q) did not panic", pairs)
+				}
+			}()
+			NewConfig(pairs)
+		}()
+		if c, err := ParseConfig(pairs); c != nil || err == nil {
+			t.Errorf("ParseConfig(%q) accepted invalid pairs", pairs)
+		}
+	}
+	if c, err := ParseConfig("<>"); err != nil || !c.IsMatched('<', '>') {
+		t.Errorf("ParseConfig rejected valid pairs: %v", err)
+	}
+}
+
+func TestBadAlphabet(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("WithAlphabet excluding a matcher did not panic")
+		}
+	}()
+	NewConfig("<>").WithAlphabet(func(b byte) bool { return b != '>' })
+}
@@ -0,0 +1,189 @@
+package matchertext
+
+import (
+	"errors"
+	"io"
+	"iter"
+)
+
+// TokenKind identifies the kind of a Token.
+type TokenKind int
+
+const (
+	TextToken  TokenKind = iota // A run of non-matcher bytes
+	OpenToken                   // The opener of a pair
+	CloseToken                  // The end of a pair
+	ErrorToken                  // A syntax or other error
+)
+
+// Token is an element of the token sequence that Tokens produces.
+type Token struct {
+	Kind TokenKind
+
+	// For a TextToken, Text holds the run of non-matcher bytes.
+	// The slice is valid only until the iteration continues,
+	// so the consumer must copy it to retain it.
+	Text []byte
+
+	// For an OpenToken or CloseToken,
+	// Matcher is the opener or closer of the pair, respectively.
+	Matcher byte
+
+	// Start and End are the byte offsets of the start and end of the token.
+	// The span of a CloseToken is empty if its pair was closed implicitly
+	// after an error, rather than by its matching closer.
+	// The span of an ErrorToken for a SyntaxError is the error's Span.
+	Start, End int64
+
+	// For an ErrorToken, Err is the error.
+	Err error
+}
+
+// Tokens returns an iterator over the tokens of the matchertext
+// read from r, according to the Standard configuration:
+//
+//	for tok := range matchertext.Tokens(r) {
+//		...
+//	}
+//
+// See Parser.Tokens for details.
+func Tokens(r io.Reader) iter.Seq[Token] {
+	return NewParser(r).Tokens()
+}
+
+// Tokens returns an iterator over the tokens of the matchertext p reads,
+// as a pull-style alternative to ReadAll and ReadFlat.
+//
+// The iterator yields a TextToken for each run of non-matcher bytes,
+// although it may split long runs across several tokens.
+// It yields an OpenToken for each opener
+// and a CloseToken at the end of each pair,
+// with OpenToken and CloseToken nesting properly as in ReadFlat.
+//
+// The iterator yields an ErrorToken for each syntax error,
+// then handles the error as ReadAll and ReadFlat do:
+// if the parser's HandleError function accepts the error,
+// the iterator recovers and continues as ReadFlat does,
+// but if HandleError rejects the error or is nil, the iterator stops.
+// Any other error, such as an I/O error or a LimitError,
+// yields a final ErrorToken.
+// The sequence ends at end-of-file or when the consumer stops ranging.
+//
+// The parser's configuration, Policy, Limits, and Context all apply.
+// The iterator parses incrementally as the consumer ranges over it,
+// and may be used only once.
+func (p *Parser) Tokens() iter.Seq[Token] {
+	return func(yield func(Token) bool) {
+		t := tokenizer{p: p, yield: yield}
+		p.onError = t.handleError
+		defer func() { p.onError = nil }()
+
+		err := p.ReadFlat(&t)
+		if err == errStopTokens {
+			return
+		}
+		if t.flush() != nil || err == nil || err == t.last {
+			return
+		}
+		tok := Token{Kind: ErrorToken, Start: p.ofs, End: p.ofs, Err: err}
+		if se, ok := err.(*SyntaxError); ok {
+			tok.Start, tok.End = se.Span()
+		}
+		yield(tok)
+	}
+}
+
+// Returned internally when the consumer of Tokens stops ranging.
+var errStopTokens = errors.New("token iteration stopped")
+
+// Maximum length of text we accumulate into a single TextToken
+const maxTokenText = 4096
+
+// tokenizer is the FlatHandler behind Parser.Tokens.
+type tokenizer struct {
+	p     *Parser
+	yield func(Token) bool
+	last  error  // last syntax error yielded
+	text  []byte // text not yet yielded
+	start int64  // offset at which text starts
+}
+
+func (t *tokenizer) Byte(b byte) error {
+	if len(t.text) == 0 {
+		t.start = t.p.ofs
+	}
+	t.text = append(t.text, b)
+	if len(t.text) >= maxTokenText {
+		return t.flush()
+	}
+	return nil
+}
+
+func (t *tokenizer) Bytes(b []byte) error {
+	if len(t.text) == 0 {
+		t.start = t.p.ofs - int64(len(b)) + 1 // p.ofs is at the last byte
+	}
+	t.text = append(t.text, b...)
+	if len(t.text) >= maxTokenText {
+		return t.flush()
+	}
+	return nil
+}
+
+func (t *tokenizer) Open(o, c byte) error {
+	if err := t.flush(); err != nil {
+		return err
+	}
+	return t.emit(Token{Kind: OpenToken, Matcher: o,
+		Start: t.p.ofs, End: t.p.ofs + 1})
+}
+
+func (t *tokenizer) Close(o, c byte) error {
+	if err := t.flush(); err != nil {
+		return err
+	}
+
+	// The pair was closed by its closer if we just consumed that closer
+	p := t.p
+	tok := Token{Kind: CloseToken, Matcher: c, Start: p.ofs, End: p.ofs}
+	if p.b < 0 && p.last == int(c) {
+		tok.End++
+	}
+	return t.emit(tok)
+}
+
+// Yield any accumulated text as a TextToken.
+func (t *tokenizer) flush() error {
+	if len(t.text) == 0 {
+		return nil
+	}
+	tok := Token{Kind: TextToken, Text: t.text,
+		Start: t.start, End: t.start + int64(len(t.text))}
+	t.text = t.text[:0]
+	return t.emit(tok)
+}
+
+// Yield a syntax error as an ErrorToken,
+// before the parser passes it on to the client's HandleError function.
+func (t *tokenizer) handleError(err error) error {
+	if e := t.flush(); e != nil {
+		return e
+	}
+	tok := Token{Kind: ErrorToken, Start: t.p.ofs, End: t.p.ofs, Err: err}
+	if se, ok := err.(*SyntaxError); ok {
+		tok.Start, tok.End = se.Span()
+	}
+	if e := t.emit(tok); e != nil {
+		return e
+	}
+	t.last = err
+	return nil
+}
+
+// Yield token tok, returning errStopTokens if the consumer has stopped.
+func (t *tokenizer) emit(tok Token) error {
+	if !t.yield(tok) {
+		return errStopTokens
+	}
+	return nil
+}
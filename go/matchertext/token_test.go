@@ -0,0 +1,153 @@
+package matchertext
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// Render a token sequence compactly for comparison
+func renderTokens(src string, seq func(func(Token) bool)) (string, error) {
+	var sb strings.Builder
+	for tok := range seq {
+		switch tok.Kind {
+		case TextToken:
+			if string(tok.Text) != src[tok.Start:tok.End] {
+				return "", errors.New("wrong text span")
+			}
+			sb.Write(tok.Text)
+		case OpenToken:
+			if src[tok.Start] != tok.Matcher || tok.End != tok.Start+1 {
+				return "", errors.New("wrong opener span")
+			}
+			sb.WriteByte('<')
+		case CloseToken:
+			if tok.Start == tok.End {
+				sb.WriteByte('|') // implicit close
+			} else if src[tok.Start] != tok.Matcher {
+				return "", errors.New("wrong closer span")
+			} else {
+				sb.WriteByte('>')
+			}
+		case ErrorToken:
+			sb.WriteByte('!')
+		}
+	}
+	return sb.String(), nil
+}
+
+func TestTokens(t *testing.T) {
+	tests := []struct{ in, out string }{
+		{"", ""},
+		{"abc", "abc"},
+		{"a(b[c]d)e", "a<b<c>d>e"},
+		{"a(b", "a<b!|"},
+		{"a)b", "a!b"},
+		{"a(b]c)d", "a<b!|!c!d"},
+		{"(]", "<!|!"},
+	}
+	for i, tt := range tests {
+		p := NewParser(strings.NewReader(tt.in))
+		p.HandleError = func(err error) error { return nil }
+		out, err := renderTokens(tt.in, p.Tokens())
+		if err != nil || out != tt.out {
+			t.Errorf("%v %q: expected %q got %q (%v)",
+				i, tt.in, tt.out, out, err)
+		}
+	}
+
+	// Without a HandleError function, the first syntax error stops
+	// the sequence, just as it stops ReadAll.
+	for i, tt := range []struct{ in, out string }{
+		{"a(b[c]d)e", "a<b<c>d>e"},
+		{"a(b", "a<b!"},
+		{"a)b(c", "a!"},
+		{"(]", "<!"},
+	} {
+		out, err := renderTokens(tt.in, Tokens(strings.NewReader(tt.in)))
+		if err != nil || out != tt.out {
+			t.Errorf("%v %q: expected %q got %q (%v)",
+				i, tt.in, tt.out, out, err)
+		}
+	}
+}
+
+// Tokens must agree with ReadFlat, both byte by byte and in runs,
+// no matter how the text is split.
+func TestTokensRandom(t *testing.T) {
+	rng := rand.New(rand.NewSource(16))
+	for i := 0; i < 500; i++ {
+		s := randString(rng, "ab\n()[]{}", rng.Intn(60))
+
+		h := &flatHandler{}
+		p := NewParser(strings.NewReader(s))
+		p.HandleError = func(err error) error { return nil }
+		if err := p.ReadFlat(h); err != nil {
+			t.Fatal(err)
+		}
+		want := strings.NewReplacer("!", "", "|", ">").Replace(h.String())
+
+		var r io.Reader = strings.NewReader(s)
+		if i%2 == 0 {
+			r = bufio.NewReaderSize(r, 16)
+		}
+		p = NewParser(r)
+		p.HandleError = func(err error) error { return nil }
+		out, err := renderTokens(s, p.Tokens())
+		if err != nil {
+			t.Fatalf("%q: %v", s, err)
+		}
+		out = strings.NewReplacer("!", "", "|", ">").Replace(out)
+		if out != want {
+			t.Errorf("%q: expected %q got %q", s, want, out)
+		}
+	}
+}
+
+func TestTokensStop(t *testing.T) {
+	// The consumer may stop ranging at any point
+	n := 0
+	for range Tokens(strings.NewReader("a(b)c(d")) {
+		if n++; n == 3 {
+			break
+		}
+	}
+	if n != 3 {
+		t.Errorf("got %v tokens", n)
+	}
+
+	// A HandleError function rejecting an error stops the sequence
+	p := NewParser(strings.NewReader("a)b(c"))
+	p.HandleError = func(err error) error { return err }
+	out, _ := renderTokens("a)b(c", p.Tokens())
+	if out != "a!" {
+		t.Errorf("rejected error: got %q", out)
+	}
+
+	// Stopping early leaves the parser handling errors as before
+	seen := 0
+	p = NewParser(strings.NewReader("a)b)c)"))
+	p.HandleError = func(err error) error { seen++; return nil }
+	for tok := range p.Tokens() {
+		if tok.Kind == ErrorToken {
+			break // before HandleError sees the error
+		}
+	}
+	if err := p.ReadAll(&testHandler{p: p}); err != nil || seen != 2 {
+		t.Errorf("after stopping, ReadAll got %v with %v errors", err, seen)
+	}
+
+	// Other errors yield a final error token
+	p = NewParser(strings.NewReader("((((("))
+	p.Limits.MaxDepth = 2
+	var last Token
+	for tok := range p.Tokens() {
+		last = tok
+	}
+	if last.Kind != ErrorToken || !errors.Is(last.Err, ErrMaxDepth) {
+		t.Errorf("expected depth limit error, got %v", last)
+	}
+}
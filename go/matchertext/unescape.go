@@ -0,0 +1,137 @@
+package matchertext
+
+import (
+	"io"
+	"sort"
+)
+
+// Unescape appends to dst the original text of src,
+// which must have been escaped using dictionary e,
+// replacing each escape sequence of e with the byte it represents.
+// Bytes not forming part of an escape sequence are copied unchanged.
+// Where several escape sequences could begin at the same position,
+// Unescape decodes the longest.
+//
+// For any text s, unescaping the output of an EscapeWriter
+// or EscapeReader with dictionary e recovers exactly s,
+// provided every escape sequence of e starts with a byte
+// that e escapes wherever it appears
+// and no escape sequence is a prefix of another,
+// as in XMLEscapes, PercentEscapes, CEscapes, and CPairEscapes.
+// The escapes decoded are then exactly those the escaper produced,
+// which replaced the matchers at the offsets UnmatchedOffsets reports
+// together with every occurrence of the escape introducer.
+//
+// MinMLEscapes lacks this property,
+// because its escape sequences start with an open bracket,
+// which it escapes only when unmatched.
+// Text that already contains a MinML matcher reference such as [(<)]
+// is left unchanged by escaping but not by unescaping,
+// so MinML-escaped text round-trips only if the original text
+// contains no MinML matcher references.
+func (e Escapes) Unescape(dst, src []byte) []byte {
+	dst, _ = newUnescaper(e).decode(dst, src, true)
+	return dst
+}
+
+// UnescapeReader is an io.Reader that reads escaped text
+// from an underlying reader and yields the original text.
+type UnescapeReader struct {
+	r   io.Reader
+	u   *unescaper
+	in  []byte // input not yet decoded
+	out []byte // decoded output not yet read
+	err error  // error from r, reported once output is exhausted
+}
+
+// NewUnescapeReader creates an UnescapeReader that reads text from r
+// and unescapes it as Unescape does, using the escape dictionary e.
+func NewUnescapeReader(r io.Reader, e Escapes) *UnescapeReader {
+	return &UnescapeReader{r: r, u: newUnescaper(e)}
+}
+
+// Read reads unescaped text into p.
+func (ur *UnescapeReader) Read(p []byte) (int, error) {
+	for len(ur.out) == 0 {
+		if ur.err != nil {
+			return 0, ur.err
+		}
+		ur.fill()
+	}
+	n := copy(p, ur.out)
+	ur.out = ur.out[n:]
+	return n, nil
+}
+
+// Read and decode the next chunk of the underlying reader,
+// holding back any bytes that might begin an incomplete escape sequence.
+func (ur *UnescapeReader) fill() {
+	var buf [4096]byte
+	n, err := ur.r.Read(buf[:])
+	ur.in = append(ur.in, buf[:n]...)
+	var m int
+	ur.out, m = ur.u.decode(ur.out[:0], ur.in, err != nil)
+	ur.in = append(ur.in[:0], ur.in[m:]...)
+	ur.err = err
+}
+
+// unescaper decodes the escape sequences of an escape dictionary.
+type unescaper struct {
+	seqs [256][]unescape // escape sequences by their first byte
+	max  int             // length of the longest escape sequence
+}
+
+type unescape struct {
+	seq string // the escape sequence
+	b   byte   // the byte it represents
+}
+
+func newUnescaper(e Escapes) *unescaper {
+	u := &unescaper{}
+	for b, seq := range e {
+		if seq == "" {
+			continue
+		}
+		u.seqs[seq[0]] = append(u.seqs[seq[0]], unescape{seq, b})
+		u.max = max(u.max, len(seq))
+	}
+	for _, us := range u.seqs {
+		sort.Slice(us, func(i, j int) bool {
+			if len(us[i].seq) != len(us[j].seq) {
+				return len(us[i].seq) > len(us[j].seq)
+			}
+			return us[i].seq < us[j].seq
+		})
+	}
+	return u
+}
+
+// Decode src, appending the result to dst,
+// and return the extended dst and the number of bytes of src consumed.
+// Unless final is true, decoding stops before any position
+// where an escape sequence might extend past the end of src.
+func (u *unescaper) decode(dst, src []byte, final bool) ([]byte, int) {
+	l := 0
+	for i := 0; i < len(src); i++ {
+		us := u.seqs[src[i]]
+		if len(us) == 0 {
+			continue
+		}
+		if !final && len(src)-i < u.max {
+			dst = append(dst, src[l:i]...)
+			return dst, i
+		}
+		for _, un := range us {
+			if len(src)-i >= len(un.seq) &&
+				string(src[i:i+len(un.seq)]) == un.seq {
+				dst = append(dst, src[l:i]...)
+				dst = append(dst, un.b)
+				i += len(un.seq) - 1
+				l = i + 1
+				break
+			}
+		}
+	}
+	dst = append(dst, src[l:]...)
+	return dst, len(src)
+}
@@ -0,0 +1,96 @@
+package matchertext
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+var unescapeTests = []struct {
+	e   Escapes
+	in  string
+	out string
+}{
+	{MinMLEscapes, "[[<]]0,1[(>)]", "[0,1)"},
+	{MinMLEscapes, "[(<)][[>]][{<}][{>}]", "(]{}"},
+	{XMLEscapes, "f(x) &amp; g&#91;y", "f(x) & g[y"},
+	{XMLEscapes, "&amp;#40;", "&#40;"},
+	{XMLEscapes, "&lt;&#40", "&lt;&#40"}, // not escapes of XMLEscapes
+	{PercentEscapes, "100%25 %28a%2", "100% (a%2"},
+	{CEscapes, `\\n\x29\\x28`, `\n)\x28`},
+	{CPairEscapes, `\o()\c[]\\o{}`, `(]\o{}`},
+	{CPairEscapes, `\o(`, `\o(`},
+}
+
+func TestUnescape(t *testing.T) {
+	for i, ut := range unescapeTests {
+		s := ut.e.Unescape(nil, []byte(ut.in))
+		if string(s) != ut.out {
+			t.Errorf("%v expecting %q got %q", i, ut.out, s)
+		}
+
+		r := NewUnescapeReader(&chunkReader{ut.in, 1 + i%3}, ut.e)
+		b, err := io.ReadAll(r)
+		if err != nil {
+			t.Errorf("%v read error %v", i, err)
+		}
+		if string(b) != ut.out {
+			t.Errorf("%v reader expecting %q got %q", i, ut.out, b)
+		}
+	}
+}
+
+// Escaping and then unescaping any text must recover the original text,
+// and the escaper must escape exactly the matchers UnmatchedOffsets reports.
+func TestEscapeRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(10))
+	dicts := []Escapes{XMLEscapes, PercentEscapes, CEscapes, CPairEscapes}
+	alpha := `ab()[]{}&#;%\xoc12345789ABCDamp`
+	for i := 0; i < 2000; i++ {
+		e := dicts[rng.Intn(len(dicts))]
+		s := randString(rng, alpha, rng.Intn(40))
+		checkRoundTrip(t, e, s)
+	}
+
+	// MinML round-trips text containing no MinML matcher references
+	for i := 0; i < 1000; i++ {
+		s := randString(rng, "ab()[]{}", rng.Intn(40))
+		checkRoundTrip(t, MinMLEscapes, s)
+	}
+}
+
+func checkRoundTrip(t *testing.T, e Escapes, s string) {
+	var w bytes.Buffer
+	ew := NewEscapeWriter(&w, e)
+	io.WriteString(ew, s)
+	ew.Close()
+
+	if u := e.Unescape(nil, w.Bytes()); string(u) != s {
+		t.Errorf("%q escaped to %q unescaped to %q", s, w.String(), u)
+	}
+	b, _ := io.ReadAll(NewUnescapeReader(&chunkReader{w.String(), 3}, e))
+	if string(b) != s {
+		t.Errorf("%q escaped to %q read back as %q", s, w.String(), b)
+	}
+
+	// The escaper must replace exactly the unmatched matchers
+	// that UnmatchedOffsets reports, plus any escape introducers.
+	os, _ := UnmatchedOffsets(strings.NewReader(s))
+	os.Sort()
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if len(os) > 0 && os[0] == int64(i) {
+			sb.WriteString(e[s[i]])
+			os = os[1:]
+		} else if esc, ok := e[s[i]]; ok && !IsMatcher(s[i]) {
+			sb.WriteString(esc)
+		} else {
+			sb.WriteByte(s[i])
+		}
+	}
+	if sb.String() != w.String() {
+		t.Errorf("%q escaped to %q, expecting %q", s, w.String(), sb.String())
+	}
+}
@@ -1,7 +1,9 @@
 package matchertext
 
 import (
+	"bufio"
 	"io"
+	"math"
 	"sort"
 
 	"github.com/dedis/matchertext/go/internal/util"
@@ -10,55 +12,74 @@ import (
 // Unmatched sets dst to a byte-mask slice the same length as src,
 // containing only unmatched matchers in src at corresponding positions.
 // Nonmatchers and matched matchers in src become zero bytes in dst.
-// If dst is nil or too small, allocates and returns a new slice.
+// If dst is nil or too small, allocates and returns a new slice;
+// otherwise returns dst truncated to the length of src.
 //
 // If the unmatched matchers in src - identified by nonzero bytes in dst -
 // are erased or replaced with matchertext-compliant escapes,
 // then the result will be valid matchertext.
 //
-// XXX not sure yet if this is really useful/needed.
-// Maybe just a general streaming escaper with a
-// customizable dictionary of escape codes would be more useful.
-func notyetUnmatched(dst, src []byte) []byte {
+// The nonzero bytes of the mask appear at exactly the offsets
+// that UnmatchedOffsets would report for the same input.
+// Unmatched is convenient for highlighting problem bytes in place,
+// while UnmatchedOffsets suits sparse results or streaming input.
+// WriteUnmatched produces the same mask from a stream.
+//
+// Unmatched uses the Standard matchertext configuration.
+func Unmatched(dst, src []byte) []byte {
+	return Standard.Unmatched(dst, src)
+}
+
+// Unmatched is like the Unmatched function
+// but identifies the unmatched matchers of configuration c.
+func (c *Config) Unmatched(dst, src []byte) []byte {
 	if len(dst) < len(src) {
 		dst = make([]byte, len(src))
 	}
+	dst = dst[:len(src)]
 
-	// scan src for unmatched matchers, forming byte mask in dst
-	d, s := dst, src
-	for len(s) > 0 {
-		d, s = unmatched(d, s)
-		if len(s) > 0 { // terminated by unmatched closer
-			d[0] = s[0]
-			s, d = d[1:], s[1:]
-		}
-	}
-
+	clear(dst)
+	unmatched(c, dst, src)
 	return dst
 }
 
-// scan for unmatched matchers in src until the first unmatched closer,
-// returning the remainder of the dst and src slices starting at that point.
-func unmatched(d, s []byte) (rd, rs []byte) {
-	for len(s) > 0 {
-		b := s[0]
-		if IsOpener(b) {
-			rd, rs := unmatched(d[1:], s[1:])
-			if len(rs) > 0 && IsMatched(b, rs[0]) { // matched pair
-				d[0], rd[0] = 0, 0
-				d, s = rd[1:], rs[1:]
+// Scan src for unmatched matchers, marking them in the zeroed byte mask d.
+// Uses an explicit stack of pending openers rather than recursion,
+// so that deeply nested input costs only one stack entry per level.
+func unmatched(cfg *Config, d, s []byte) {
+	var stack []int // offsets of pending openers
+	for i := 0; i < len(s); i++ {
+		i += indexMatcher(cfg, s[i:])
+		if i == len(s) {
+			break
+		}
+		b := s[i]
+		if cfg.IsOpener(b) {
+			stack = append(stack, i)
+			continue
+		}
+
+		// Pop openers until we find one this closer matches,
+		// leaving openers closed without matching unmatched.
+		matched := false
+		for len(stack) > 0 && !matched {
+			o := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if cfg.IsMatched(s[o], b) {
+				matched = true
 			} else {
-				d[0] = b // unmatched opener
-				d, s = rd, rs
+				d[o] = s[o] // unmatched opener
 			}
-		} else if IsCloser(b) {
-			return d, s // unmatched closer
-		} else {
-			d[0] = 0 // nonmatcher
-			d, s = d[1:], s[1:]
+		}
+		if !matched {
+			d[i] = b // unmatched closer
 		}
 	}
-	return d, s
+
+	// Openers still pending at the end are unmatched
+	for _, o := range stack {
+		d[o] = s[o]
+	}
 }
 
 // Unmatched reads io.Reader r and returns
@@ -68,71 +89,125 @@ func unmatched(d, s []byte) (rd, rs []byte) {
 // but sort.Sort() may be used to sort the resulting slice if needed.
 // Returns a nil OffsetSlice if input r is valid matchertext.
 // Returns an error only if an I/O error occurs while reading r.
+//
+// UnmatchedOffsets keeps an entry for each pending opener,
+// so its memory use grows with the nesting depth of the input.
+// UnmatchedOffsetsLimits can bound this and other costs
+// of scanning untrusted input.
+//
+// UnmatchedOffsets uses the Standard matchertext configuration.
 func UnmatchedOffsets(r io.Reader) (OffsetSlice, error) {
+	return Standard.UnmatchedOffsets(r)
+}
+
+// UnmatchedOffsetsLimits is like UnmatchedOffsets,
+// but stops reading r with a LimitError on exceeding limits l,
+// just as a Parser with those Limits would.
+// It counts the nesting depth and number of pairs by pending openers,
+// not yet knowing which of them are unmatched.
+// On exceeding a limit, it returns the offsets of the unmatched matchers
+// identified so far along with the LimitError.
+//
+// UnmatchedOffsetsLimits uses the Standard matchertext configuration.
+func UnmatchedOffsetsLimits(r io.Reader, l Limits) (OffsetSlice, error) {
+	return Standard.UnmatchedOffsetsLimits(r, l)
+}
+
+// UnmatchedOffsets reads io.Reader r and returns
+// a slice listing the byte offsets in r, if any,
+// at which matchers of configuration c appear unmatched.
+// The alphabet of c does not affect the result.
+func (c *Config) UnmatchedOffsets(r io.Reader) (OffsetSlice, error) {
+	return c.UnmatchedOffsetsLimits(r, Limits{})
+}
+
+// UnmatchedOffsetsLimits is like the UnmatchedOffsetsLimits function
+// but identifies the unmatched matchers of configuration c.
+func (c *Config) UnmatchedOffsetsLimits(r io.Reader, l Limits) (
+	OffsetSlice, error) {
+
 	br := util.ToByteScanner(r)
 
-	// Scan the input stream until we reach io.EOF or another I/O error
-	_, os, err := unmatchedScan(br, true, 0, nil)
+	// Scan the input stream until we reach io.EOF or another error
+	os, err := unmatchedScan(c, br, l)
 	if err != io.EOF {
 		return os, err
 	}
 	return os, nil // successful completion
 }
 
-func unmatchedScan(br io.ByteScanner, all bool, ofs int64, os OffsetSlice) (
-	int64, OffsetSlice, error) {
+// An opener awaiting its closer in unmatchedScan
+type pendingOpener struct {
+	ofs int64 // offset of the opener
+	o   byte  // the opener itself
+}
 
+// Scan br for unmatched matchers until end-of-file, another I/O error,
+// or exceeding limits l.
+// Uses an explicit stack of pending openers rather than recursion,
+// so that deeply nested input costs only one stack entry per level.
+// Reports unmatched matchers in the same order a recursive scan would:
+// each unmatched closer and each unmatched opener
+// at the point its enclosing scan ends.
+func unmatchedScan(cfg *Config, br io.ByteScanner, l Limits) (
+	OffsetSlice, error) {
+
+	var os OffsetSlice
+	var stack []pendingOpener
+	bbr, buffered := br.(*bufio.Reader)
+	ofs, pairs := int64(0), int64(0)
 	for {
 		b, err := br.ReadByte()
 		if err != nil {
-			return ofs, os, err
+			if err == io.EOF { // pending openers are unmatched
+				for i := len(stack) - 1; i >= 0; i-- {
+					os = append(os, stack[i].ofs)
+				}
+			}
+			return os, err
+		}
+		if l.MaxBytes > 0 && ofs >= l.MaxBytes {
+			return os, &LimitError{ErrMaxBytes, l.MaxBytes, ofs}
 		}
 
 		switch {
-		case IsOpener(b):
-			o := b
-
-			// Recursively scan the contents of the matcher pair
-			newOfs, newOs, err := unmatchedScan(
-				br, false, ofs+1, os)
-
-			// Scan the hopefully-matching closer
-			if err == nil {
-				b, err = br.ReadByte()
+		case cfg.IsOpener(b):
+			if l.MaxDepth > 0 && len(stack) >= l.MaxDepth {
+				return os, &LimitError{ErrMaxDepth, int64(l.MaxDepth), ofs}
 			}
-
-			// Check that the opener is properly closed
-			switch {
-			case err == io.EOF:
-				newOs = append(newOs, ofs) // unmatched opener
-				err = nil                  // consume error
-
-			case err != nil: // other I/O errors are fatal
-				return newOfs, newOs, err
-
-			case IsMatched(o, b):
-				newOfs++ // account for closer
-
-			default:
-				newOs = append(newOs, ofs) // unmatched opener
-				err = br.UnreadByte()      // don't eat closer
+			if pairs++; l.MaxPairs > 0 && pairs > l.MaxPairs {
+				return os, &LimitError{ErrMaxPairs, l.MaxPairs, ofs}
+			}
+			stack = append(stack, pendingOpener{ofs, b})
+
+		case cfg.IsCloser(b):
+			// Pop openers until we find one this closer matches,
+			// leaving openers closed without matching unmatched.
+			matched := false
+			for len(stack) > 0 && !matched {
+				po := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				if cfg.IsMatched(po.o, b) {
+					matched = true
+				} else {
+					os = append(os, po.ofs) // unmatched opener
+				}
+			}
+			if !matched {
+				os = append(os, ofs) // unmatched closer
 			}
-			ofs, os = newOfs, newOs
-
-		case IsCloser(b) && all:
-			// Record but then scan past this unmatched closer
-			os = append(os, ofs)
-			ofs++
-
-		case IsCloser(b):
-			// Stop and return before this unmatched closer
-			err = br.UnreadByte()
-			return ofs, os, err
 
 		default:
-			// Scan past non-matchers
-			ofs++
+			// Skip runs of non-matchers at once in buffered input
+			if buffered {
+				limit := math.MaxInt
+				if l.MaxBytes > 0 {
+					limit = int(min(l.MaxBytes-ofs-1, math.MaxInt32))
+				}
+				ofs += int64(skipText(cfg, bbr, limit))
+			}
 		}
+		ofs++
 	}
 }
 
@@ -1,6 +1,11 @@
 package matchertext
 
 import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"runtime"
 	"strings"
 	"testing"
 )
@@ -57,3 +62,226 @@ func eqOffsetSlice(a, b OffsetSlice) bool {
 	}
 	return true
 }
+
+func TestUnmatchedOffsetsConfig(t *testing.T) {
+	tests := []struct {
+		cfg *Config
+		s   string
+		os  OffsetSlice
+	}{
+		{Braces, "[0,1) {x}", nil},
+		{Braces, "{[}(", nil},
+		{Braces, "}{", OffsetSlice{0, 1}},
+		{NewConfig("<>"), "a<b>(c>", OffsetSlice{6}},
+		{Graphic, "a (b]", OffsetSlice{2, 4}},
+	}
+	for i, ut := range tests {
+		os, err := ut.cfg.UnmatchedOffsets(strings.NewReader(ut.s))
+		if err != nil {
+			t.Errorf("%v error: %v", i, err.Error())
+			continue
+		}
+		os.Sort()
+		if !eqOffsetSlice(os, ut.os) {
+			t.Errorf("%v expecting %v got %v", i, ut.os, os)
+		}
+	}
+}
+
+func TestUnmatched(t *testing.T) {
+	for i, ut := range unmatchedTests {
+		mask := Unmatched(nil, []byte(ut.s))
+		if len(mask) != len(ut.s) {
+			t.Errorf("%v mask has length %v", i, len(mask))
+			continue
+		}
+		var os OffsetSlice
+		for j, b := range mask {
+			if b != 0 {
+				os = append(os, int64(j))
+				if b != ut.s[j] {
+					t.Errorf("%v mask byte %v is %q", i, j, b)
+				}
+			}
+		}
+		if !eqOffsetSlice(os, ut.os) {
+			t.Errorf("%v expecting %v got %v", i, ut.os, os)
+		}
+
+		// The streaming version must produce the identical mask
+		var sb strings.Builder
+		err := WriteUnmatched(&sb, strings.NewReader(ut.s))
+		if err != nil {
+			t.Errorf("%v error: %v", i, err.Error())
+		}
+		if sb.String() != string(mask) {
+			t.Errorf("%v expecting mask %q got %q", i, mask, sb.String())
+		}
+	}
+
+	// A larger destination slice is reused and truncated
+	dst := make([]byte, 10)
+	if m := Unmatched(dst, []byte("a)")); len(m) != 2 || &m[0] != &dst[0] {
+		t.Errorf("destination slice not reused")
+	}
+}
+
+// Reader that returns its input in tiny chunks.
+type chunkReader struct {
+	s string
+	n int
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	if len(r.s) == 0 {
+		return 0, io.EOF
+	}
+	n := min(len(p), r.n, len(r.s))
+	copy(p, r.s[:n])
+	r.s = r.s[n:]
+	return n, nil
+}
+
+func TestWriteUnmatchedRandom(t *testing.T) {
+	rng := rand.New(rand.NewSource(6))
+	for _, c := range []*Config{Standard, Braces} {
+		for i := 0; i < 1000; i++ {
+			s := randString(rng, "ab()[]{}", rng.Intn(60))
+			var sb strings.Builder
+			r := &chunkReader{s, 1 + rng.Intn(5)}
+			if err := c.WriteUnmatched(&sb, r); err != nil {
+				t.Fatal(err)
+			}
+			mask := c.Unmatched(nil, []byte(s))
+			if sb.String() != string(mask) {
+				t.Errorf("%q: expected %q got %q",
+					s, mask, sb.String())
+			}
+		}
+	}
+}
+
+// The original recursive scan, kept as a reference for the iterative one.
+func recursiveUnmatchedScan(br io.ByteScanner, all bool, ofs int64,
+	os OffsetSlice) (int64, OffsetSlice, error) {
+
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return ofs, os, err
+		}
+
+		switch {
+		case IsOpener(b):
+			o := b
+			newOfs, newOs, err := recursiveUnmatchedScan(
+				br, false, ofs+1, os)
+			if err == nil {
+				b, err = br.ReadByte()
+			}
+			switch {
+			case err == io.EOF:
+				newOs = append(newOs, ofs)
+			case err != nil:
+				return newOfs, newOs, err
+			case IsMatched(o, b):
+				newOfs++
+			default:
+				newOs = append(newOs, ofs)
+				br.UnreadByte()
+			}
+			ofs, os = newOfs, newOs
+
+		case IsCloser(b) && all:
+			os = append(os, ofs)
+			ofs++
+
+		case IsCloser(b):
+			return ofs, os, br.UnreadByte()
+
+		default:
+			ofs++
+		}
+	}
+}
+
+// The iterative scan must report unmatched matchers
+// in exactly the order the recursive scan did.
+func TestUnmatchedOffsetsOrder(t *testing.T) {
+	rng := rand.New(rand.NewSource(14))
+	for i := 0; i < 2000; i++ {
+		s := randString(rng, "ab()[]{}", rng.Intn(60))
+		_, want, _ := recursiveUnmatchedScan(strings.NewReader(s),
+			true, 0, nil)
+		got, err := UnmatchedOffsets(strings.NewReader(s))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !eqOffsetSlice(got, want) {
+			t.Errorf("%q: expected %v got %v", s, want, got)
+		}
+	}
+}
+
+// Input nested depth levels deep.
+func nested(depth int) []byte {
+	return []byte(strings.Repeat("([{", depth/3) +
+		strings.Repeat("}])", depth/3))
+}
+
+// Run op repeatedly on input nested depth levels deep,
+// reporting the heap and stack memory it uses per nesting level.
+func benchNesting(b *testing.B, depth int, op func()) {
+	b.ReportAllocs()
+	b.SetBytes(int64(depth * 2))
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	n := 0
+	for b.Loop() {
+		op()
+		n++
+	}
+	runtime.ReadMemStats(&after)
+	heap := float64(after.TotalAlloc-before.TotalAlloc) / float64(n)
+	b.ReportMetric(heap/float64(depth), "heapB/level")
+	b.ReportMetric(float64(stackUse(op))/float64(depth), "stackB/level")
+}
+
+// Return the approximate goroutine stack memory op grows to use.
+func stackUse(op func()) uint64 {
+	ch := make(chan uint64)
+	go func() {
+		var m runtime.MemStats
+		runtime.ReadMemStats(&m)
+		base := m.StackInuse
+		op()
+		runtime.ReadMemStats(&m)
+		ch <- max(m.StackInuse, base) - base
+	}()
+	return <-ch
+}
+
+var nestingDepths = []int{1000, 100000}
+
+func BenchmarkUnmatchedNesting(b *testing.B) {
+	for _, depth := range nestingDepths {
+		src := nested(depth)
+		b.Run(fmt.Sprintf("Recursive/%v", depth), func(b *testing.B) {
+			benchNesting(b, depth, func() {
+				recursiveUnmatchedScan(bytes.NewReader(src),
+					true, 0, nil)
+			})
+		})
+		b.Run(fmt.Sprintf("UnmatchedOffsets/%v", depth), func(b *testing.B) {
+			benchNesting(b, depth, func() {
+				UnmatchedOffsets(bytes.NewReader(src))
+			})
+		})
+		dst := make([]byte, len(src))
+		b.Run(fmt.Sprintf("Unmatched/%v", depth), func(b *testing.B) {
+			benchNesting(b, depth, func() {
+				Unmatched(dst, src)
+			})
+		})
+	}
+}
@@ -0,0 +1,560 @@
+// Package regex translates between standard Go regular expressions
+// and a matchertext-compliant regular expression syntax,
+// in which every matcher appears as part of a matched pair,
+// so that a regular expression may be embedded verbatim
+// in any matchertext host language.
+//
+// The matchertext syntax extends Go's regexp syntax in three ways,
+// following the matchertext paper.
+// Outside character classes, the paired escapes \o() and \c()
+// represent a literal open or close parenthesis,
+// and similarly \o[], \c[], \o{}, and \c{} represent brackets and braces.
+// The escape \m[m] represents the embedded matchertext m literally,
+// with no character in m having any special meaning.
+//
+// Within a bracketed character class, which extends to the close bracket
+// matching its open bracket rather than the first close bracket,
+// matchers stand for themselves, so that [()[]{}] matches any matcher
+// and [^[]] matches anything but a square bracket.
+// A less-than sign between a matcher pair selects the opener alone,
+// and a greater-than sign the closer alone,
+// so that [a-z{<}] matches a lower-case letter or an open brace,
+// and [^[>]] matches anything but a close bracket.
+//
+// Standard Go regular expressions containing no unmatched matchers
+// are usually valid in the matchertext syntax with the same meaning,
+// the exceptions being some character classes containing matchers.
+// Check reports whether a standard regular expression is such an expression.
+// Translate converts a matchertext-compliant regular expression
+// into Go's standard syntax, and FromStandard converts in the other direction.
+package regex
+
+import (
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/dedis/matchertext/go/matchertext"
+)
+
+// UnmatchedError reports that a regular expression
+// is not valid matchertext.
+type UnmatchedError struct {
+	Expr    string                  // the regular expression
+	Offsets matchertext.OffsetSlice // offsets of its unmatched matchers
+}
+
+// Error returns a human-readable description of the error.
+func (e *UnmatchedError) Error() string {
+	var b strings.Builder
+	b.WriteString("regex: unmatched matchers")
+	for _, ofs := range e.Offsets {
+		b.WriteByte(' ')
+		b.WriteByte(e.Expr[ofs])
+	}
+	b.WriteString(" in ")
+	b.WriteString(e.Expr)
+	return b.String()
+}
+
+// Compile translates the matchertext-compliant regular expression expr
+// into Go's standard syntax and compiles it with regexp.Compile.
+func Compile(expr string) (*regexp.Regexp, error) {
+	s, err := Translate(expr)
+	if err != nil {
+		return nil, err
+	}
+	return regexp.Compile(s)
+}
+
+// MustCompile is like Compile but panics if the expression is invalid.
+func MustCompile(expr string) *regexp.Regexp {
+	re, err := Compile(expr)
+	if err != nil {
+		panic(`regex: Compile(` + expr + `): ` + err.Error())
+	}
+	return re
+}
+
+// ChangedError reports that a standard regular expression
+// means something different in the matchertext syntax.
+type ChangedError struct {
+	Expr       string // the standard regular expression
+	Translated string // its translation from the matchertext syntax
+}
+
+// Error returns a human-readable description of the error.
+func (e *ChangedError) Error() string {
+	return "regex: " + e.Expr + " means " + e.Translated +
+		" in matchertext syntax"
+}
+
+// Check reports whether the standard Go regular expression expr
+// is already matchertext-compliant:
+// that is, whether it is valid matchertext
+// and has the same meaning in the matchertext syntax as in Go's.
+// Check returns a *syntax.Error if expr is not a valid regular expression,
+// an *UnmatchedError if it contains unmatched matchers,
+// or a *ChangedError if its meaning differs in the matchertext syntax,
+// as it does for a character class like [[a]]
+// that Go ends at its first close bracket.
+func Check(expr string) error {
+	std, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil {
+		return err
+	}
+	offs, _ := matchertext.UnmatchedOffsets(strings.NewReader(expr))
+	if len(offs) > 0 {
+		offs.Sort()
+		return &UnmatchedError{expr, offs}
+	}
+	s, err := Translate(expr)
+	if err != nil {
+		return err
+	}
+	if re, _ := syntax.Parse(s, syntax.Perl); re.String() != std.String() {
+		return &ChangedError{expr, s}
+	}
+	return nil
+}
+
+// QuoteMeta returns a matchertext-compliant regular expression
+// that matches the literal text s.
+// Like regexp.QuoteMeta, it returns s itself if s contains
+// no characters special in regular expressions.
+// Otherwise, if s is valid matchertext, QuoteMeta embeds it as \m[s].
+func QuoteMeta(s string) string {
+	q := regexp.QuoteMeta(s)
+	if q == s {
+		return s
+	}
+	if offs, _ := matchertext.UnmatchedOffsets(
+		strings.NewReader(s)); len(offs) == 0 {
+		return `\m[` + s + `]`
+	}
+	r, err := FromStandard(q)
+	if err != nil {
+		panic("regex: QuoteMeta produced invalid expression " + q)
+	}
+	return r
+}
+
+// Translate converts the matchertext-compliant regular expression expr
+// into an equivalent regular expression in Go's standard syntax,
+// and checks that the result is valid using regexp/syntax.
+// Translate does not require expr to be valid matchertext
+// outside its character classes and \m escapes,
+// so it also accepts most standard regular expressions unchanged.
+//
+// Translate returns a *syntax.Error if expr is invalid.
+func Translate(expr string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(expr); {
+		switch c := expr[i]; {
+		case c == '\\' && i+1 < len(expr):
+			n, err := translateEscape(&b, expr, i, false)
+			if err != nil {
+				return "", err
+			}
+			i += n
+
+		case c == '[':
+			end, err := matchertext.Extract([]byte(expr), i)
+			if err != nil {
+				return "", &syntax.Error{Code: syntax.ErrMissingBracket,
+					Expr: expr[i:]}
+			}
+			if err := translateClass(&b, expr[i:end+1]); err != nil {
+				return "", err
+			}
+			i = end + 1
+
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+
+	s := b.String()
+	if _, err := syntax.Parse(s, syntax.Perl); err != nil {
+		return "", err
+	}
+	return s, nil
+}
+
+// Translate the escape sequence at offset i of expr,
+// returning its length in expr.
+// Within a character class, \m escapes are not allowed.
+func translateEscape(b *strings.Builder, expr string, i int, class bool) (
+	int, error) {
+
+	rest := expr[i:]
+	switch c := rest[1]; {
+	case (c == 'o' || c == 'c') && len(rest) >= 4:
+		o, cl := rest[2], rest[3]
+		if !matchertext.IsMatched(o, cl) {
+			break
+		}
+		b.WriteByte('\\')
+		if c == 'o' {
+			b.WriteByte(o)
+		} else {
+			b.WriteByte(cl)
+		}
+		return 4, nil
+
+	case c == 'm' && !class && len(rest) > 2 && rest[2] == '[':
+		end, err := matchertext.Extract([]byte(rest), 2)
+		if err != nil {
+			break
+		}
+		b.WriteString(regexp.QuoteMeta(rest[3:end]))
+		return end + 1, nil
+
+	case (c == 'p' || c == 'P' || c == 'x') && len(rest) > 2 &&
+		rest[2] == '{':
+		// Braces delimiting a Unicode class name or a character code
+		n := strings.IndexByte(rest, '}') + 1
+		if n == 0 {
+			n = len(rest)
+		}
+		b.WriteString(rest[:n])
+		return n, nil
+
+	case c == 'Q' && !class:
+		// Quoted text is literal through \E or the end of the expression
+		n := strings.Index(rest, `\E`)
+		if n < 0 {
+			n = len(rest)
+		} else {
+			n += 2
+		}
+		b.WriteString(rest[:n])
+		return n, nil
+
+	case c == 'o' || c == 'c' || c == 'm':
+		// Go rejects these anyway, but we can report the whole sequence
+
+	default:
+		b.WriteString(rest[:2])
+		return 2, nil
+	}
+	return 0, &syntax.Error{Code: syntax.ErrInvalidEscape,
+		Expr: rest[:min(len(rest), 4)]}
+}
+
+// Translate the character class cls, from its open bracket
+// through its matching close bracket, into Go's standard syntax.
+func translateClass(b *strings.Builder, cls string) error {
+	b.WriteByte('[')
+	body := cls[1 : len(cls)-1]
+	if strings.HasPrefix(body, "^") {
+		b.WriteByte('^')
+		body = body[1:]
+	}
+	for i := 0; i < len(body); {
+		c := body[i]
+		switch {
+		case c == '\\' && i+1 < len(body):
+			n, err := translateEscape(b, body, i, true)
+			if err != nil {
+				return err
+			}
+			i += n
+
+		case c == '[' && strings.HasPrefix(body[i:], "[:"): //matchertext:allow
+			// Named character class like [:alpha:]
+			n := strings.Index(body[i:], ":]") //matchertext:allow
+			if n < 0 {
+				return &syntax.Error{Code: syntax.ErrMissingBracket,
+					Expr: cls}
+			}
+			b.WriteString(body[i : i+n+2])
+			i += n + 2
+
+		case matchertext.IsOpener(c) && i+2 < len(body) &&
+			(body[i+1] == '<' || body[i+1] == '>') &&
+			matchertext.IsMatched(c, body[i+2]):
+			// Select the opener or closer of the pair
+			b.WriteByte('\\')
+			if body[i+1] == '<' {
+				b.WriteByte(c)
+			} else {
+				b.WriteByte(body[i+2])
+			}
+			i += 3
+
+		case matchertext.IsMatcher(c):
+			b.WriteByte('\\')
+			b.WriteByte(c)
+			i++
+
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	b.WriteByte(']')
+	return nil
+}
+
+// FromStandard converts the standard Go regular expression expr
+// into an equivalent matchertext-compliant regular expression.
+// It rewrites only the literal matchers that would otherwise be unmatched,
+// using paired escapes like \o() outside character classes
+// and selections like (<) within them,
+// leaving the rest of expr intact.
+//
+// FromStandard returns a *syntax.Error if expr is invalid.
+func FromStandard(expr string) (string, error) {
+	if _, err := syntax.Parse(expr, syntax.Perl); err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	i := 0
+	for _, e := range standardEdits(expr) {
+		b.WriteString(expr[i:e.ofs])
+		b.WriteString(e.text)
+		i = e.ofs + e.n
+	}
+	b.WriteString(expr[i:])
+	return b.String(), nil
+}
+
+// edit replaces n bytes at offset ofs of an expression with text.
+type edit struct {
+	ofs, n int
+	text   string
+}
+
+// literal is a literal matcher in a standard regular expression,
+// either bare or escaped with a backslash.
+type literal struct {
+	ofs, n int  // offset and length in the expression
+	c      byte // the matcher it represents
+	class  bool // whether it is within a character class
+	quoted bool // whether it is within \Q...\E
+}
+
+// Return the edits, in order, that make the valid standard regular
+// expression expr matchertext-compliant without changing its meaning.
+//
+// The structural matchers delimiting groups, character classes,
+// and repetitions always match in a valid regular expression.
+// The literal matchers must match among themselves
+// within each region that the structural matchers delimit,
+// so we apply the unmatched-matcher rule within each region separately,
+// and escape the literal matchers it finds unmatched.
+func standardEdits(expr string) []edit {
+	var edits []edit
+	unmatched := func(l literal) {
+		var text string
+		switch {
+		case l.class && matchertext.IsOpener(l.c):
+			text = string([]byte{l.c, '<', matchertext.Standard.Closer(l.c)})
+		case l.class:
+			text = string([]byte{opener(l.c), '>', l.c})
+		case l.quoted:
+			text = `\E` + matchertext.CPairEscapes[l.c] + `\Q`
+		default:
+			text = matchertext.CPairEscapes[l.c]
+		}
+		edits = append(edits, edit{l.ofs, l.n, text})
+	}
+
+	// Pending literal openers in each enclosing region
+	regions := [][]literal{nil}
+	push := func() {
+		regions = append(regions, nil)
+	}
+	pop := func() {
+		for _, l := range regions[len(regions)-1] {
+			unmatched(l)
+		}
+		regions = regions[:len(regions)-1]
+	}
+	add := func(l literal) {
+		top := &regions[len(regions)-1]
+		if matchertext.IsOpener(l.c) {
+			*top = append(*top, l)
+			return
+		}
+		for len(*top) > 0 {
+			o := (*top)[len(*top)-1]
+			*top = (*top)[:len(*top)-1]
+			if matchertext.IsMatched(o.c, l.c) {
+				return
+			}
+			unmatched(o)
+		}
+		unmatched(l)
+	}
+
+	for i := 0; i < len(expr); {
+		switch c := expr[i]; {
+		case c == '\\':
+			switch d := expr[i+1]; {
+			case d == 'Q':
+				n := strings.Index(expr[i+2:], `\E`)
+				if n < 0 {
+					n = len(expr) - i - 2
+				}
+				for j := i + 2; j < i+2+n; j++ {
+					if matchertext.IsMatcher(expr[j]) {
+						add(literal{ofs: j, n: 1, c: expr[j], quoted: true})
+					}
+				}
+				i += n + 4
+			case matchertext.IsMatcher(d):
+				add(literal{ofs: i, n: 2, c: d})
+				i += 2
+			default:
+				i += escapeLen(expr[i:])
+			}
+
+		case c == '[':
+			push()
+			i = classEdits(expr, i, add, &edits)
+			pop()
+
+		case c == '(':
+			push()
+			i++
+
+		case c == ')':
+			pop()
+			i++
+
+		case c == '{' && repeatLen(expr[i:]) > 0:
+			i += repeatLen(expr[i:])
+
+		case matchertext.IsMatcher(c):
+			add(literal{ofs: i, n: 1, c: c})
+			i++
+
+		default:
+			i++
+		}
+	}
+	pop()
+
+	sort.Slice(edits, func(i, j int) bool {
+		return edits[i].ofs < edits[j].ofs
+	})
+	return edits
+}
+
+// Add the literal matchers of the character class starting at offset i
+// of the standard regular expression expr, returning the offset following it.
+// Also escape any bare opener that a selection like (<) would misinterpret.
+func classEdits(expr string, i int, add func(literal), edits *[]edit) int {
+	i++
+	if i < len(expr) && expr[i] == '^' {
+		i++
+	}
+	if i < len(expr) && expr[i] == ']' {
+		// A leading close bracket is literal
+		add(literal{ofs: i, n: 1, c: ']', class: true})
+		i++
+	}
+	for i < len(expr) {
+		switch c := expr[i]; {
+		case c == ']':
+			return i + 1
+
+		case c == '[' && strings.HasPrefix(expr[i:], "[:") && //matchertext:allow
+			strings.Contains(expr[i+2:], ":]"): //matchertext:allow
+			// A named class like [:alpha:], which Go finds this way
+			i += strings.Index(expr[i+2:], ":]") + 4 //matchertext:allow
+
+		case c == '\\' && i+1 < len(expr) &&
+			strings.IndexByte("pPdDsSwW", expr[i+1]) >= 0:
+			// A Unicode or Perl class, which cannot start a range
+			i += escapeLen(expr[i:])
+
+		default:
+			// A single character, which may start a range
+			// whose end Go never takes as a named class
+			i = classChar(expr, i, add, edits)
+			if i+1 < len(expr) && expr[i] == '-' && expr[i+1] != ']' {
+				i = classChar(expr, i+1, add, edits)
+			}
+		}
+	}
+	return i
+}
+
+// Add the literal matcher, if any, of the single character
+// at offset i in a character class of the standard regular expression expr,
+// returning the offset following it.
+func classChar(expr string, i int, add func(literal), edits *[]edit) int {
+	switch c := expr[i]; {
+	case c == '\\' && i+1 < len(expr):
+		if d := expr[i+1]; matchertext.IsMatcher(d) {
+			add(literal{ofs: i, n: 2, c: d, class: true})
+			return i + 2
+		}
+		return i + escapeLen(expr[i:])
+
+	case matchertext.IsMatcher(c):
+		if (c == '(' || c == '{') && i+2 < len(expr) &&
+			(expr[i+1] == '<' || expr[i+1] == '>') &&
+			expr[i+2] == matchertext.Standard.Closer(c) {
+			*edits = append(*edits, edit{i, 0, `\`})
+		}
+		add(literal{ofs: i, n: 1, c: c, class: true})
+		return i + 1
+	}
+	_, n := utf8.DecodeRuneInString(expr[i:])
+	return i + n
+}
+
+// Return the length of the escape sequence at the start of s,
+// which is not \Q.
+func escapeLen(s string) int {
+	switch s[1] {
+	case 'p', 'P', 'x':
+		if len(s) > 2 && s[2] == '{' {
+			return strings.IndexByte(s, '}') + 1
+		}
+	}
+	return 2
+}
+
+// Return the length of the repetition like {2,3} at the start of s,
+// or zero if s does not start with a repetition.
+func repeatLen(s string) int {
+	i := 1
+	digits := func() int {
+		j := i
+		for i < len(s) && '0' <= s[i] && s[i] <= '9' {
+			i++
+		}
+		return i - j
+	}
+	if digits() == 0 {
+		return 0
+	}
+	if i < len(s) && s[i] == ',' {
+		i++
+		digits()
+	}
+	if i < len(s) && s[i] == '}' {
+		return i + 1
+	}
+	return 0
+}
+
+// Return the opener matching closer c.
+func opener(c byte) byte {
+	switch c {
+	case ')':
+		return '('
+	case ']':
+		return '['
+	}
+	return '{'
+}
@@ -0,0 +1,213 @@
+package regex
+
+import (
+	"errors"
+	"regexp/syntax"
+	"strings"
+	"testing"
+
+	"github.com/dedis/matchertext/go/matchertext"
+)
+
+func TestTranslate(t *testing.T) {
+	for i, tc := range []struct{ in, out string }{
+		// Examples from the matchertext paper
+		{`[a-z{<}]`, `[a-z\{]`},
+		{`[^[>]]`, `[^\]]`},
+		{`[()[]{}]`, `[\(\)\[\]\{\}]`},
+		{`[^[]]`, `[^\[\]]`},
+		{`([a-z]|\o{})`, `([a-z]|\{)`},
+
+		{``, ``},
+		{`a(b|c)*d`, `a(b|c)*d`},
+		{`f\o()x\c()`, `f\(x\)`},
+		{`\o[]\c[]\o{}\c{}`, `\[\]\{\}`},
+		{`x\m[a.b(c)]y`, `xa\.b\(c\)y`},
+		{`\m[]`, ``},
+		{`[[:alpha:]_]`, `[[:alpha:]_]`},
+		{`[^<[]]`, `[^<\[\]]`},
+		{`[\o()\c()]`, `[\(\)]`},
+		{`[(>)a-z]`, `[\)a-z]`},
+		{`[\[\]]`, `[\[\]]`},
+		{`\p{Greek}+[\p{Lu}(<)]`, `\p{Greek}+[\p{Lu}\(]`},
+		{`\x{5B}a{2,3}`, `\x{5B}a{2,3}`},
+		{`\Q[(\E`, `\Q[(\E`},
+	} {
+		out, err := Translate(tc.in)
+		if err != nil || out != tc.out {
+			t.Errorf("%v %q: got %q, %v, expected %q",
+				i, tc.in, out, err, tc.out)
+		}
+	}
+}
+
+func TestTranslateError(t *testing.T) {
+	for i, tc := range []struct {
+		in   string
+		code syntax.ErrorCode
+	}{
+		{`[a-z`, syntax.ErrMissingBracket},
+		{`[a-z)]`, syntax.ErrMissingBracket},
+		{`\o(]`, syntax.ErrInvalidEscape},
+		{`\c`, syntax.ErrInvalidEscape},
+		{`\m[x`, syntax.ErrInvalidEscape},
+		{`[\m[x]]`, syntax.ErrInvalidEscape},
+		{`a(b`, syntax.ErrMissingParen},
+		{`\q`, syntax.ErrInvalidEscape},
+	} {
+		_, err := Translate(tc.in)
+		var se *syntax.Error
+		if !errors.As(err, &se) || se.Code != tc.code {
+			t.Errorf("%v %q: got %v, expected %v", i, tc.in, err, tc.code)
+		}
+	}
+}
+
+func TestFromStandard(t *testing.T) {
+	for i, tc := range []struct{ in, out string }{
+		{``, ``},
+		{`a(b|c)*d`, `a(b|c)*d`},
+		{`\(`, `\o()`},
+		{`\(a\)`, `\(a\)`},
+		{`\((a)`, `\o()(a)`},
+		{`(\()`, `(\o())`},
+		{`a]b}`, `a\c[]b\c{}`},
+		{`a{b`, `a\o{}b`},
+		{`a{2}}`, `a{2}\c{}`},
+		{`[(]`, `[(<)]`},
+		{`[^\]]`, `[^[>]]`},
+		{`[]a]`, `[[>]a]`},
+		{`[[a]]`, `[[<]a]\c[]`},
+		{`[()]`, `[()]`},
+		{`[(<)]`, `[\(<)]`},
+		{`[[:alpha:][]`, `[[:alpha:][<]]`},
+		{`[+-[:a:]`, `[+-[<]:a:]`},
+		{`x[!-[:digit:]`, `x[!-[<]:digit:]`},
+		{`[\d-[:digit:]]`, `[\d-[:digit:]]`},
+		{`[\p{Lu}{]`, `[\p{Lu}{<}]`},
+		{`\Q(\E`, `\Q\E\o()\Q\E`},
+		{`\Qa(b`, `\Qa\E\o()\Qb`},
+		{`\[\d{2,}`, `\o[]\d{2,}`},
+	} {
+		out, err := FromStandard(tc.in)
+		if err != nil || out != tc.out {
+			t.Errorf("%v %q: got %q, %v, expected %q",
+				i, tc.in, out, err, tc.out)
+		}
+	}
+
+	if _, err := FromStandard(`a(b`); err == nil {
+		t.Errorf("FromStandard accepted invalid expression")
+	}
+}
+
+// Converting a standard regular expression into the matchertext syntax
+// and back must preserve its meaning, and the result must be matchertext.
+func TestRoundTrip(t *testing.T) {
+	for i, expr := range []string{
+		`a(b|c)*d`, `\(`, `\)\(`, `[(]`, `[)]`, `[^\]]`, `[]a]`, `[[a]]`,
+		`[(<)]`, `[{>}]`, `[[<]]`, `[a[<]`, `a{2}}`, `\{\d{1,3}\}`,
+		`[[:alpha:][]`, `\Q)(\E`, `\pL\p{Greek}[\x{7B}-\x{7D}]`,
+		`(?i)(?P<name>[^()\[\]{}]+)\]`, `^\[([^\]]*)\]$`, `[\[(][\])]`,
+		`[+-[:a:]`, `x[!-[:digit:]`, `[\d-[:digit:]]`, `[\x21-[:a:]]`,
+	} {
+		mt, err := FromStandard(expr)
+		if err != nil {
+			t.Errorf("%v %q: %v", i, expr, err)
+			continue
+		}
+		if offs, _ := matchertext.UnmatchedOffsets(
+			strings.NewReader(mt)); len(offs) > 0 {
+			t.Errorf("%v %q: %q has unmatched matchers %v",
+				i, expr, mt, offs)
+		}
+		std, err := Translate(mt)
+		if err != nil {
+			t.Errorf("%v %q: Translate(%q): %v", i, expr, mt, err)
+			continue
+		}
+		re1, _ := syntax.Parse(expr, syntax.Perl)
+		re2, _ := syntax.Parse(std, syntax.Perl)
+		if re1.String() != re2.String() {
+			t.Errorf("%v %q: translated %q back to %q",
+				i, expr, mt, std)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	for i, tc := range []struct {
+		expr string
+		err  any
+	}{
+		{`a(b|c)*d`, nil},
+		{`\(a\)[()]`, nil},
+		{`[[:digit:]]{2,3}`, nil},
+		{`\(`, &UnmatchedError{}},
+		{`a]`, &UnmatchedError{}},
+		{`[[a]]`, &ChangedError{}},
+		{`[(<)]`, &ChangedError{}},
+		{`a(`, &syntax.Error{}},
+	} {
+		err := Check(tc.expr)
+		switch tc.err.(type) {
+		case nil:
+			if err != nil {
+				t.Errorf("%v %q: %v", i, tc.expr, err)
+			}
+		case *UnmatchedError:
+			var ue *UnmatchedError
+			if !errors.As(err, &ue) {
+				t.Errorf("%v %q: got %v, expected unmatched", i, tc.expr, err)
+			}
+		case *ChangedError:
+			var ce *ChangedError
+			if !errors.As(err, &ce) {
+				t.Errorf("%v %q: got %v, expected changed", i, tc.expr, err)
+			}
+		case *syntax.Error:
+			var se *syntax.Error
+			if !errors.As(err, &se) {
+				t.Errorf("%v %q: got %v, expected syntax error",
+					i, tc.expr, err)
+			}
+		}
+	}
+
+	err := Check(`\)\(`).(*UnmatchedError)
+	if len(err.Offsets) != 2 || err.Offsets[0] != 1 || err.Offsets[1] != 3 {
+		t.Errorf("Check found unmatched offsets %v", err.Offsets)
+	}
+}
+
+func TestCompile(t *testing.T) {
+	for i, tc := range []struct{ expr, in, match string }{
+		{`[a-z{<}]+`, "x{y}", "x{y"},
+		{`[^[>]]+`, "ab]c", "ab"},
+		{`[()[]{}]+`, "a([{}])b", "([{}])"},
+		{`\o()[0-9]+\c()`, "f(12)", "(12)"},
+		{`\m[(a+b)*c]`, "x(a+b)*c", "(a+b)*c"},
+	} {
+		if m := MustCompile(tc.expr).FindString(tc.in); m != tc.match {
+			t.Errorf("%v %q on %q: got %q, expected %q",
+				i, tc.expr, tc.in, m, tc.match)
+		}
+	}
+}
+
+func TestQuoteMeta(t *testing.T) {
+	for i, tc := range []struct{ in, out string }{
+		{"abc", "abc"},
+		{"a.b(c)", `\m[a.b(c)]`},
+		{"x(", `x\o()`},
+		{"[a", `\o[]a`},
+	} {
+		out := QuoteMeta(tc.in)
+		if out != tc.out {
+			t.Errorf("%v %q: got %q, expected %q", i, tc.in, out, tc.out)
+		}
+		if !MustCompile(`^` + out + `$`).MatchString(tc.in) {
+			t.Errorf("%v %q: %q does not match", i, tc.in, out)
+		}
+	}
+}
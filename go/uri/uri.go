@@ -0,0 +1,442 @@
+// Package uri supports the matchertext extensions to URI syntax
+// that the matchertext paper proposes.
+//
+// A matchertext escape %[m] embeds the matchertext m in a URI verbatim.
+// The URI processor does not interpret m
+// except to verify that its matchers match and to find its end,
+// so that percent-encoding is disabled within m:
+// for example, %[100%] is equivalent to 100%25,
+// and file:///%[a<b>c`d] names a file a<b>c`d.
+// The matchertext quote [m] likewise embeds m verbatim,
+// but is not an escape: its brackets remain part of the URI,
+// so that [@] is equivalent to %5B%40%5D.
+// Within the authority of a URI, brackets retain their standard meaning
+// of delimiting an IP literal such as [::1].
+//
+// The embedded matchertext m may contain any graphical characters
+// in valid UTF-8, but no spaces or control codes,
+// which a URI must still percent-encode outside any embedded matchertext.
+//
+// Standard converts a URI using these extensions
+// into an equivalent standard RFC 3986 URI,
+// and FromStandard converts in the other direction.
+// ParseMatchertextURL parses a URI using these extensions into a url.URL,
+// whose decoded components then reflect any embedded matchertext.
+package uri
+
+import (
+	"io"
+	"net/url"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/dedis/matchertext/go/matchertext"
+)
+
+// ParseMatchertextURL parses a URI that may use matchertext escapes
+// and quotes into a url.URL, by converting it to a standard URI
+// and parsing the result with url.Parse.
+// The components of the resulting url.URL, such as its Path,
+// and the values its Query method returns,
+// contain the embedded matchertext decoded.
+func ParseMatchertextURL(rawURL string) (*url.URL, error) {
+	s, err := Standard(rawURL)
+	if err != nil {
+		return nil, &url.Error{Op: "parse", URL: rawURL, Err: err}
+	}
+	return url.Parse(s)
+}
+
+// Standard converts uri, which may use matchertext escapes and quotes,
+// into an equivalent standard RFC 3986 URI.
+// It replaces each matchertext escape %[m]
+// with the percent-encoding of m,
+// and each matchertext quote [m] outside the authority
+// with the percent-encoding of the brackets and m.
+// Standard percent-encodes every byte of m except unreserved characters
+// and the sub-delimiters ! * ' ( ),
+// since m may contain characters such as / and ?
+// that would otherwise delimit URI components.
+// Other text in uri passes through unchanged,
+// except that any curly braces or stray close brackets are percent-encoded,
+// since standard URIs do not permit them.
+//
+// Standard returns a matchertext.SyntaxError
+// if the embedded matchertext is invalid.
+func Standard(uri string) (string, error) {
+	var b strings.Builder
+	err := scan(uri, func(kind partKind, s string) {
+		switch kind {
+		case textPart:
+			for i := 0; i < len(s); i++ {
+				switch c := s[i]; c {
+				case '{', '}', ']':
+					b.WriteString(matchertext.PercentEscapes[c])
+				default:
+					b.WriteByte(c)
+				}
+			}
+		case escapePart:
+			b.WriteString(percentEncode(s))
+		case quotePart:
+			b.WriteString(percentEncode("[" + s + "]"))
+		case literalPart:
+			b.WriteString("[" + s + "]")
+		}
+	})
+	if err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// Unescape decodes a single URI component s, such as a path segment,
+// that may use matchertext escapes and quotes.
+// It decodes each percent-encoded byte %XX,
+// replaces each matchertext escape %[m] with m,
+// and leaves each matchertext quote [m] intact, including its brackets.
+// Unlike url.QueryUnescape, Unescape does not decode + as a space.
+//
+// Unescape returns a url.EscapeError if s contains a malformed escape,
+// or a matchertext.SyntaxError if the embedded matchertext is invalid.
+func Unescape(s string) (string, error) {
+	var b strings.Builder
+	var err error
+	serr := scanComponent(s, func(kind partKind, p string) {
+		switch kind {
+		case textPart:
+			for len(p) > 0 && err == nil {
+				i := strings.IndexByte(p, '%')
+				if i < 0 {
+					b.WriteString(p)
+					break
+				}
+				b.WriteString(p[:i])
+				if i+3 > len(p) || !isHex(p[i+1]) || !isHex(p[i+2]) {
+					err = url.EscapeError(p[i:min(i+3, len(p))])
+					break
+				}
+				b.WriteByte(unhex(p[i+1])<<4 | unhex(p[i+2]))
+				p = p[i+3:]
+			}
+		case escapePart:
+			b.WriteString(p)
+		case quotePart, literalPart:
+			b.WriteString("[" + p + "]")
+		}
+	})
+	if serr != nil {
+		return "", serr
+	}
+	if err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// Escape encodes an arbitrary string s for use as a URI component,
+// using a matchertext escape where it helps.
+// Escape returns s unchanged if it needs no escaping in a standard URI
+// and contains no unmatched parentheses.
+// Otherwise, if s is valid matchertext consisting only of graphical
+// characters, Escape returns the matchertext escape %[s].
+// Otherwise, Escape percent-encodes s as Standard would,
+// but also percent-encodes any unmatched parentheses,
+// so that the result remains valid matchertext.
+func Escape(s string) string {
+	mask := matchertext.Unmatched(nil, []byte(s))
+	plain := true
+	for i := 0; i < len(s); i++ {
+		if shouldEscape(s[i]) || mask[i] != 0 {
+			plain = false
+			break
+		}
+	}
+	switch {
+	case plain:
+		return s
+	case isEmbeddable(s, mask):
+		return "%[" + s + "]"
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if shouldEscape(s[i]) || mask[i] != 0 {
+			writeEscape(&b, s[i])
+		} else {
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// FromStandard converts a standard RFC 3986 URI into an equivalent URI
+// using matchertext escapes to make percent-encoded components readable.
+// It rewrites each path segment, query key or value, or fragment
+// containing percent-encoded bytes as a matchertext escape %[m],
+// where m is the decoded component,
+// provided that m is valid matchertext of graphical characters.
+// It leaves the scheme and authority, other components,
+// and query components containing a + sign, which some servers decode
+// as a space, unchanged.
+//
+// FromStandard returns a url.EscapeError
+// if uri contains a malformed percent-encoding.
+func FromStandard(uri string) (string, error) {
+	var b strings.Builder
+	var err error
+	rewrite := func(part string, query bool) {
+		if err != nil || !strings.Contains(part, "%") ||
+			query && strings.Contains(part, "+") {
+			b.WriteString(part)
+			return
+		}
+		var dec string
+		dec, err = url.PathUnescape(part)
+		if err == nil && isEmbeddable(dec, nil) {
+			part = "%[" + dec + "]"
+		}
+		b.WriteString(part)
+	}
+	join := func(s, sep string, fn func(string)) {
+		for i, part := range strings.Split(s, sep) {
+			if i > 0 {
+				b.WriteString(sep)
+			}
+			fn(part)
+		}
+	}
+
+	rest := uri
+	n := schemeLen(rest)
+	if strings.HasPrefix(rest[n:], "//") {
+		n += 2 + authorityLen(rest[n+2:])
+	}
+	b.WriteString(rest[:n])
+	rest, frag, hasFrag := strings.Cut(rest[n:], "#")
+	path, query, hasQuery := strings.Cut(rest, "?")
+	join(path, "/", func(seg string) { rewrite(seg, false) })
+	if hasQuery {
+		b.WriteByte('?')
+		join(query, "&", func(kv string) {
+			join(kv, "=", func(p string) { rewrite(p, true) })
+		})
+	}
+	if hasFrag {
+		b.WriteByte('#')
+		rewrite(frag, false)
+	}
+	if err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// partKind identifies the kind of part of a URI that scan reports.
+type partKind int
+
+const (
+	textPart    partKind = iota // ordinary URI text
+	escapePart                  // the matchertext m of an escape %[m]
+	quotePart                   // the matchertext m of a quote [m]
+	literalPart                 // the content of an IP literal [m]
+)
+
+// Scan the parts of uri, calling fn for each,
+// while tracking which part of uri is its authority.
+func scan(uri string, fn func(partKind, string)) error {
+	n := schemeLen(uri)
+	authority := strings.HasPrefix(uri[n:], "//")
+	if authority {
+		n += 2
+	}
+	fn(textPart, uri[:n])
+	return scanParts(uri, n, authority, fn)
+}
+
+// Scan the parts of a single URI component s, which has no authority.
+func scanComponent(s string, fn func(partKind, string)) error {
+	return scanParts(s, 0, false, fn)
+}
+
+// Scan the parts of s starting at offset start,
+// with the authority extending from there
+// to the first slash, question mark, or number sign if authority is true.
+// Uses a matchertext parser to find the end of embedded matchertext.
+func scanParts(s string, start int, authority bool,
+	fn func(partKind, string)) error {
+
+	// Restrict only the embedded matchertext to graphical characters,
+	// leaving the validation of other URI text to the client.
+	p := matchertext.NewParser(strings.NewReader(s))
+	var m strings.Builder
+	extract := func(kind partKind) error {
+		m.Reset()
+		p.Policy = matchertext.PolicyURI
+		_, _, err := p.Extract(&m)
+		p.Policy = 0
+		if err != nil {
+			return err
+		}
+		fn(kind, m.String())
+		return nil
+	}
+
+	var text strings.Builder
+	flush := func() {
+		if text.Len() > 0 {
+			fn(textPart, text.String())
+			text.Reset()
+		}
+	}
+
+	// Skip the part we have already reported
+	for range start {
+		if _, err := p.ReadByte(); err != nil {
+			return err
+		}
+	}
+	for {
+		b, err := p.PeekByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case b == '[':
+			flush()
+			kind := quotePart
+			if authority {
+				kind = literalPart
+			}
+			if err := extract(kind); err != nil {
+				return err
+			}
+			continue
+
+		case b == '%':
+			p.ReadByte()
+			if b, err := p.PeekByte(); err == nil && b == '[' {
+				flush()
+				if err := extract(escapePart); err != nil {
+					return err
+				}
+				continue
+			}
+
+		case authority && (b == '/' || b == '?' || b == '#'):
+			authority = false
+			p.ReadByte()
+
+		default:
+			p.ReadByte()
+		}
+		text.WriteByte(b)
+	}
+	flush()
+	return nil
+}
+
+// Return the length of the scheme of uri, including its colon,
+// or zero if uri has no scheme.
+func schemeLen(uri string) int {
+	for i := 0; i < len(uri); i++ {
+		switch c := uri[i]; {
+		case 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z':
+		case '0' <= c && c <= '9' || c == '+' || c == '-' || c == '.':
+			if i == 0 {
+				return 0
+			}
+		case c == ':' && i > 0:
+			return i + 1
+		default:
+			return 0
+		}
+	}
+	return 0
+}
+
+// Return the length of the authority at the start of s.
+func authorityLen(s string) int {
+	if i := strings.IndexAny(s, "/?#"); i >= 0 {
+		return i
+	}
+	return len(s)
+}
+
+// Return true if s is non-empty valid matchertext in valid UTF-8
+// consisting only of graphical characters,
+// and thus embeddable in a matchertext escape.
+// If mask is non-nil, it holds the unmatched matchers of s.
+func isEmbeddable(s string, mask []byte) bool {
+	if s == "" || !utf8.ValidString(s) {
+		return false
+	}
+	for _, r := range s {
+		if !unicode.IsGraphic(r) || unicode.IsSpace(r) {
+			return false
+		}
+	}
+	if mask == nil {
+		mask = matchertext.Unmatched(nil, []byte(s))
+	}
+	for _, b := range mask {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Return true if byte c must be percent-encoded in a URI component.
+// Leaves unreserved characters and the sub-delimiters ! * ' ( ) unescaped,
+// as JavaScript's encodeURIComponent does.
+func shouldEscape(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return false
+	}
+	switch c {
+	case '-', '_', '.', '~', '!', '*', '\'', '(', ')':
+		return false
+	}
+	return true
+}
+
+// Percent-encode s for use as a URI component.
+func percentEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if shouldEscape(s[i]) {
+			writeEscape(&b, s[i])
+		} else {
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+func writeEscape(b *strings.Builder, c byte) {
+	const hex = "0123456789ABCDEF"
+	b.WriteByte('%')
+	b.WriteByte(hex[c>>4])
+	b.WriteByte(hex[c&15])
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case c <= '9':
+		return c - '0'
+	case c <= 'F':
+		return c - 'A' + 10
+	}
+	return c - 'a' + 10
+}
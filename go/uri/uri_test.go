@@ -0,0 +1,177 @@
+package uri
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/dedis/matchertext/go/matchertext"
+)
+
+// Examples from the matchertext paper, in standard and matchertext form.
+var paperExamples = []struct{ std, mt string }{
+	{"http://dev.site/myLibrary/doc/genericContainer%3CT%3E/api/",
+		"http://dev.site/myLibrary/doc/%[genericContainer<T>]/api/"},
+	{"http://search.engine/linksto?site=http%3A%2F%2Fmy.site%2F&results=50",
+		"http://search.engine/linksto?site=%[http://my.site/]&results=50"},
+	{"http://calculator.site/?expr=(1%2B2)*3%5E4%2F5",
+		"http://calculator.site/?expr=%[(1+2)*3^4/5]"},
+	{"http://social.net/user/joe%40email.net/index.html",
+		"http://social.net/user/%[joe@email.net]/index.html"},
+	{"file:///a%3Cb%3Ec%60d", "file:///%[a<b>c`d]"},
+	{"100%25", "%[100%]"},
+}
+
+func TestStandard(t *testing.T) {
+	for i, ex := range paperExamples {
+		std, err := Standard(ex.mt)
+		if err != nil || std != ex.std {
+			t.Errorf("%v %q: got %q, %v, expected %q",
+				i, ex.mt, std, err, ex.std)
+		}
+	}
+	for i, tc := range []struct{ in, out string }{
+		{"", ""},
+		{"http://example.com/a%20b", "http://example.com/a%20b"},
+		{"http://[::1]:80/[@]", "http://[::1]:80/%5B%40%5D"},
+		{"//[fe80::1%25en0]/x?q=[a&b]", "//[fe80::1%25en0]/x?q=%5Ba%26b%5D"},
+		{"/p/%[a/b?c#d]/q", "/p/a%2Fb%3Fc%23d/q"},
+		{"/%[f(x[i]){y}]", "/f(x%5Bi%5D)%7By%7D"},
+		{"/%[%[x]]", "/%25%5Bx%5D"},
+		{"/{x}]", "/%7Bx%7D%5D"},
+		{"/%", "/%"},
+		{"mailto:%[joe@x]", "mailto:joe%40x"},
+	} {
+		out, err := Standard(tc.in)
+		if err != nil || out != tc.out {
+			t.Errorf("%v %q: got %q, %v, expected %q",
+				i, tc.in, out, err, tc.out)
+		}
+	}
+}
+
+func TestStandardErrors(t *testing.T) {
+	for i, tc := range []struct {
+		in   string
+		kind matchertext.ErrorKind
+		ofs  int64
+	}{
+		{"/%[abc", matchertext.UnmatchedOpener, 2},
+		{"/%[a(b]c)", matchertext.MismatchedCloser, 4},
+		{"/x/[a", matchertext.UnmatchedOpener, 3},
+		{"/%[a b]", matchertext.DisallowedByte, 4},
+		{"/%[a\x01]", matchertext.DisallowedByte, 4},
+		{"/%[a\u00a0]", matchertext.DisallowedRune, 4},
+	} {
+		_, err := Standard(tc.in)
+		var se *matchertext.SyntaxError
+		if !errors.As(err, &se) || se.Kind() != tc.kind || se.Offset() != tc.ofs {
+			t.Errorf("%v %q: got error %v, expected %v at %v",
+				i, tc.in, err, tc.kind, tc.ofs)
+		}
+	}
+}
+
+func TestFromStandard(t *testing.T) {
+	for i, ex := range paperExamples {
+		mt, err := FromStandard(ex.std)
+		if err != nil || mt != ex.mt {
+			t.Errorf("%v %q: got %q, %v, expected %q",
+				i, ex.std, mt, err, ex.mt)
+		}
+	}
+	for i, tc := range []struct{ in, out string }{
+		{"http://a%40b@host/x", "http://a%40b@host/x"},
+		{"/a%20b/c%29", "/a%20b/c%29"},
+		{"/?q=a%2Fb+c", "/?q=a%2Fb+c"},
+		{"/x#sec%3C1%3E", "/x#%[sec<1>]"},
+	} {
+		out, err := FromStandard(tc.in)
+		if err != nil || out != tc.out {
+			t.Errorf("%v %q: got %q, %v, expected %q",
+				i, tc.in, out, err, tc.out)
+		}
+	}
+	if _, err := FromStandard("/a%zz"); err == nil {
+		t.Errorf("malformed escape accepted")
+	}
+}
+
+func TestUnescape(t *testing.T) {
+	for i, tc := range []struct{ in, out string }{
+		{"abc", "abc"},
+		{"a%20b+c", "a b+c"},
+		{"%[100%]", "100%"},
+		{"%[a<b>c`d]", "a<b>c`d"},
+		{"x%[(1+2)]y%2F", "x(1+2)y/"},
+		{"[@]%40", "[@]@"},
+		{"[%[x]]", "[%[x]]"},
+	} {
+		out, err := Unescape(tc.in)
+		if err != nil || out != tc.out {
+			t.Errorf("%v %q: got %q, %v, expected %q",
+				i, tc.in, out, err, tc.out)
+		}
+	}
+	for i, in := range []string{"%", "a%2", "%zz", "%[x", "[x"} {
+		if out, err := Unescape(in); err == nil {
+			t.Errorf("%v %q: unescaped as %q", i, in, out)
+		}
+	}
+}
+
+func TestEscape(t *testing.T) {
+	for i, tc := range []struct{ in, out string }{
+		{"", ""},
+		{"abc-1.2_~", "abc-1.2_~"},
+		{"f(x)", "f(x)"},
+		{"a/b", "%[a/b]"},
+		{"genericContainer<T>", "%[genericContainer<T>]"},
+		{"100%", "%[100%]"},
+		{"open(", "open%28"},
+		{"close)open(", "close%29open%28"},
+		{"a b(", "a%20b%28"},
+		{"[x", "%5Bx"},
+	} {
+		out := Escape(tc.in)
+		if out != tc.out {
+			t.Errorf("%v %q: got %q, expected %q", i, tc.in, out, tc.out)
+		}
+		if dec, err := Unescape(out); err != nil || dec != tc.in {
+			t.Errorf("%v %q: unescaped as %q, %v", i, out, dec, err)
+		}
+	}
+}
+
+func TestParseMatchertextURL(t *testing.T) {
+	u, err := ParseMatchertextURL(
+		"http://[::1]:8080/doc/%[genericContainer<T>]/[@]" +
+			"?site=%[http://my.site/?a=b&c]&n=1#%[sec<1>]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Host != "[::1]:8080" || u.Path != "/doc/genericContainer<T>/[@]" ||
+		u.Fragment != "sec<1>" {
+		t.Errorf("host %q path %q fragment %q", u.Host, u.Path, u.Fragment)
+	}
+	q := u.Query()
+	if q.Get("site") != "http://my.site/?a=b&c" || q.Get("n") != "1" {
+		t.Errorf("query %v", q)
+	}
+
+	// Paper examples parse the same in both forms
+	for i, ex := range paperExamples {
+		su, err1 := url.Parse(ex.std)
+		mu, err2 := ParseMatchertextURL(ex.mt)
+		if err1 != nil || err2 != nil || su.String() != mu.String() {
+			t.Errorf("%v: %v, %v parsed as %v, %v", i, err1, err2, su, mu)
+		}
+	}
+
+	_, err = ParseMatchertextURL("http://x/%[oops")
+	var ue *url.Error
+	var se *matchertext.SyntaxError
+	if !errors.As(err, &ue) || !errors.As(err, &se) {
+		t.Errorf("unexpected error %v", err)
+	}
+}